@@ -1,12 +1,21 @@
 package types
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -42,7 +51,7 @@ type Command struct {
 	// cmd is the command name to execute
 	cmd string
 	// cmdFn is an optional function to execute instead of a system command
-	cmdFn func(stdin string) (stdout, stderr string, err error)
+	cmdFn func(stdin []byte) (stdout, stderr []byte, err error)
 	// args are the command arguments
 	args []string
 	// interactive indicates if the command should connect to the terminal
@@ -54,9 +63,9 @@ type Command struct {
 	// executed tracks if the command has been run
 	executed bool
 	// stdout holds the captured stdout
-	stdout string
+	stdout []byte
 	// stderr holds the captured stderr
-	stderr string
+	stderr []byte
 	// err holds any execution error
 	err error
 	// ctx is the context for cancellation/timeout
@@ -73,6 +82,82 @@ type Command struct {
 	retryCount int
 	// retryDelay is the delay between retries
 	retryDelay time.Duration
+	// seqOp is the operator of a conditional sequence built with And, Or or Then ("&&", "||" or ";")
+	seqOp string
+	// seqLeft is the command executed first in a conditional sequence
+	seqLeft *Command
+	// seqRight is the command executed depending on seqLeft result and seqOp
+	seqRight *Command
+	// ran holds the commands a conditional sequence executed, in order
+	ran []*Command
+	// teeBranches are the commands receiving a copy of the previous command stdout
+	teeBranches []*Command
+	// pipeFrom selects which output streams of previous are piped to this command
+	pipeFrom pipeStream
+	// teePolicy controls how output is delivered to teeBranches
+	teePolicy TeePolicy
+	// xargs runs a sub-command for each batch of input lines when set
+	xargs *xargs
+	// parallel runs an instance of the command for each chunk of input when set
+	parallel *parallelStage
+	// requirements are version constraints checked by Validate
+	requirements []requirement
+	// captureInteractive captures stdout and stderr while showing them in interactive mode
+	captureInteractive bool
+	// recordStdin records the terminal input in interactive mode
+	recordStdin bool
+	// stdin holds the recorded terminal input
+	stdin string
+	// allowedExitCodes are non-zero exit codes that are not errors
+	allowedExitCodes []int
+	// exitCodeMeanings describe exit codes in error messages
+	exitCodeMeanings map[int]string
+	// running is set when the command is started in the background
+	running *running
+	// idleTimeout is the maximum duration without output before the command is killed
+	idleTimeout time.Duration
+	// idle is the watchdog of the running process when idleTimeout is set
+	idle *idleWatchdog
+	// script is the inline script run by the interpreter in cmd, set by Script
+	script *script
+	// cache stores and serves the command results across processes when set
+	cache *commandCache
+	// redactEnv are environment variables whose values are secrets
+	redactEnv []string
+	// redactOutput replaces secrets in the returned output
+	redactOutput bool
+	// inheritEnv are the only variables inherited when set, with inheritEnvPrefix
+	inheritEnv []string
+	// inheritEnvPrefix are prefixes of the only variables inherited when set, with inheritEnv
+	inheritEnvPrefix []string
+	// envFiles are dotenv files loaded when the command is executed
+	envFiles []string
+	// expandEnv expands ${VAR} in arguments with the effective environment
+	expandEnv bool
+	// expandGlobs expands glob patterns and braces in arguments relative to dir
+	expandGlobs bool
+	// globMode controls patterns matching no file when expandGlobs is set
+	globMode GlobMode
+	// forwardSignals forwards the program signals to the command while it runs
+	forwardSignals bool
+	// signals forwards signals to the running process when forwardSignals is set
+	signals *signalForwarder
+	// timeline records the order of the process writes to stdout and stderr
+	timeline *timeline
+	// audit records the processes executed by the command when set
+	audit *AuditLog
+	// started is when the process start was attempted, zero before
+	started time.Time
+	// limit is the limiter the process waits for before starting when set
+	limit *commandLimit
+	// limitedDownstream is set when the command streams to commands that hold the limiter slots for it
+	limitedDownstream bool
+	// fnName is the name of the function of cmdFn, cmdFn wraps it for CmdFn
+	fnName string
+	// secrets are the values marked with Secret
+	secrets []string
+	// redactor replaces the secrets of the command tree, it's built when the command is executed
+	redactor *redactor
 }
 
 // Cmd creates a new Command with the given command name and arguments.
@@ -99,13 +184,43 @@ func Cmd(cmd string, args ...string) *Command {
 //	upperCase := types.CmdFn(func(stdin string) (string, string, error) {
 //		return strings.ToUpper(stdin), "", nil
 //	})
-//	result := types.Cmd("echo", "hello").PipeFn(upperCase.cmdFn).Stdout() // "HELLO\n"
+//	result := upperCase.Input("hello").Stdout() // "HELLO"
 func CmdFn(fn func(stdin string) (stdout, stderr string, outErr error)) *Command {
+	cmd := CmdFnBytes(func(stdin []byte) ([]byte, []byte, error) {
+		stdout, stderr, err := fn(string(stdin))
+		return []byte(stdout), []byte(stderr), err
+	})
+	cmd.fnName = funcName(fn)
+
+	return cmd
+}
+
+// CmdFnBytes is like CmdFn for binary data, stdin and outputs are passed as bytes without
+// conversion to strings. The function must not modify stdin or keep it after returning.
+//
+// Example:
+//
+//	decompress := types.CmdFnBytes(func(stdin []byte) ([]byte, []byte, error) {
+//		r, err := gzip.NewReader(bytes.NewReader(stdin))
+//		if err != nil {
+//			return nil, nil, err
+//		}
+//		out, err := io.ReadAll(r)
+//		return out, nil, err
+//	})
+//	data := decompress.InputBytes(archive).StdoutBytes()
+func CmdFnBytes(fn func(stdin []byte) (stdout, stderr []byte, outErr error)) *Command {
 	return &Command{
-		cmdFn: fn,
+		cmdFn:  fn,
+		fnName: funcName(fn),
 	}
 }
 
+// funcName returns the name of the function fn, like main.main.func1 for a function literal
+func funcName(fn any) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
+
 // Pipe chains another command to receive this command's stdout as stdin.
 // This creates a pipeline similar to shell pipes (|).
 //
@@ -128,6 +243,39 @@ func (c *Command) Pipe(cmd string, args ...string) *Command {
 	return next
 }
 
+// PipeStderr chains another command to receive this command's stderr as stdin.
+// Stdout is still captured as usual. This is useful for tools that write their
+// useful diagnostics to stderr, like compilers or ffmpeg.
+//
+// Unlike Pipe, the stderr of an earlier command that exited with an error is still
+// piped, the pipeline then fails with that error. Commands that couldn't start stop
+// the pipeline like with Pipe.
+//
+// Example:
+//
+//	warnings := types.Cmd("gcc", "-Wall", "main.c").
+//		PipeStderr("grep", "warning").
+//		Stdout()
+func (c *Command) PipeStderr(cmd string, args ...string) *Command {
+	next := c.Pipe(cmd, args...)
+	next.pipeFrom = pipeStderr
+
+	return next
+}
+
+// PipeBoth chains another command to receive this command's stdout and stderr
+// merged as stdin, similar to shell |&. Both streams are still captured separately.
+//
+// Example:
+//
+//	lines := types.Cmd("make").PipeBoth("wc", "-l").StdoutTrimmed()
+func (c *Command) PipeBoth(cmd string, args ...string) *Command {
+	next := c.Pipe(cmd, args...)
+	next.pipeFrom = pipeBoth
+
+	return next
+}
+
 // PipeFn chains a function to receive this command's stdout as stdin.
 // This allows inserting custom transformations into command pipelines.
 //
@@ -145,6 +293,82 @@ func (c *Command) PipeFn(fn func(stdin string) (stdout, stderr string, outErr er
 	return next
 }
 
+// PipeFnBytes is like PipeFn for binary data, see CmdFnBytes.
+//
+// Example:
+//
+//	size := types.Cmd("cat", "image.png").
+//		PipeFnBytes(func(stdin []byte) ([]byte, []byte, error) {
+//			return []byte(strconv.Itoa(len(stdin))), nil, nil
+//		}).
+//		Stdout()
+func (c *Command) PipeFnBytes(fn func(stdin []byte) (stdout, stderr []byte, outErr error)) *Command {
+	next := CmdFnBytes(fn)
+	next.previous = c
+
+	return next
+}
+
+// And chains next to run only if this command succeeds, similar to shell &&.
+// The returned Command is a sequence that executes lazily like any other Command.
+// Its stdout and stderr are the concatenated outputs of the commands that ran,
+// and its error and exit code are those of the last command that ran.
+//
+// Example:
+//
+//	result := types.Cmd("make", "build").
+//		And(types.Cmd("make", "test")).
+//		Or(types.Cmd("notify-send", "build failed"))
+//	result.String() // "make build && make test || notify-send build failed"
+func (c *Command) And(next *Command) *Command { return sequence(c, "&&", next) }
+
+// Or chains next to run only if this command fails, similar to shell ||.
+// See And for how the outputs of the sequence are combined.
+//
+// Example:
+//
+//	err := types.Cmd("test", "-d", "build").Or(types.Cmd("mkdir", "build")).Error()
+func (c *Command) Or(next *Command) *Command { return sequence(c, "||", next) }
+
+// Then chains next to run after this command regardless of its result, similar to shell ;.
+// See And for how the outputs of the sequence are combined.
+//
+// Example:
+//
+//	output := types.Cmd("echo", "one").Then(types.Cmd("echo", "two")).Stdout() // "one\ntwo\n"
+func (c *Command) Then(next *Command) *Command { return sequence(c, ";", next) }
+
+func sequence(left *Command, op string, right *Command) *Command {
+	return &Command{
+		seqOp:    op,
+		seqLeft:  left,
+		seqRight: right,
+	}
+}
+
+// Ran executes the command and returns the commands that actually ran, in order.
+// For a sequence built with And, Or or Then, commands skipped by the operators are
+// not included, each returned command holds its own output, error and exit code.
+// For Xargs and ForEachLine it returns the sub-commands that ran,
+// and for PipeParallel the instances that processed each chunk.
+// For any other command it returns the command itself.
+//
+// Example:
+//
+//	seq := types.Cmd("false").And(types.Cmd("echo", "skipped")).Or(types.Cmd("echo", "fallback"))
+//	for _, cmd := range seq.Ran() {
+//		fmt.Println(cmd, cmd.ExitCode()) // "false 1" then "echo fallback 0"
+//	}
+func (c *Command) Ran() []*Command {
+	c.execute()
+
+	if c.seqOp == "" && c.xargs == nil && c.parallel == nil {
+		return []*Command{c}
+	}
+
+	return c.ran
+}
+
 // Interactive sets the command to run in interactive mode.
 // In interactive mode, stdin/stdout/stderr are connected directly to the terminal
 // instead of being captured. This is useful for commands that require user input
@@ -158,6 +382,46 @@ func (c *Command) Interactive() *Command {
 	return c
 }
 
+// InteractiveCapture is like Interactive, but stdout and stderr are also captured
+// while being shown on the terminal, so Stdout and Stderr return a transcript of the run.
+// Use RecordStdin to also capture what is typed.
+//
+// The output goes through pipes, not a terminal, so programs that check for one disable
+// colors, progress bars and line editing, like when their output is redirected.
+//
+// Example:
+//
+//	deploy := types.Cmd("./deploy.sh").InteractiveCapture()
+//	if err := deploy.Error(); err != nil {
+//		report(err, deploy.Stdout(), deploy.Stderr())
+//	}
+func (c *Command) InteractiveCapture() *Command {
+	c.interactive = true
+	c.captureInteractive = true
+	return c
+}
+
+// RecordStdin records the terminal input passed to an interactive command, it's
+// available from RecordedStdin after execution. It has no effect on commands that
+// read stdin from Input, InputReader or a pipe.
+//
+// Recording passes stdin through a pipe, so the command doesn't see the terminal as its
+// stdin. Recording stops when the command exits, except on Windows and other systems
+// where the first input typed after the command exits is consumed by the recorder.
+//
+// Example:
+//
+//	cmd := types.Cmd("bc").InteractiveCapture().RecordStdin()
+//	cmd.Run()
+//	transcript := cmd.RecordedStdin()
+func (c *Command) RecordStdin() *Command {
+	c.recordStdin = true
+	return c
+}
+
+// RecordedStdin executes the command and returns the terminal input recorded with RecordStdin
+func (c *Command) RecordedStdin() string { return c.redact(c.execute().stdin) }
+
 // Input sets the stdin for the command from a string.
 // This is useful for providing input to commands that read from stdin.
 //
@@ -183,6 +447,19 @@ func (c *Command) InputReader(r io.Reader) *Command {
 	return c
 }
 
+// InputBytes sets the stdin for the command from bytes, useful for binary input.
+// The bytes must not be modified until the command is executed.
+//
+// Example:
+//
+//	thumbnail := types.Cmd("convert", "-", "-resize", "64x64", "png:-").
+//		InputBytes(image).
+//		StdoutBytes()
+func (c *Command) InputBytes(input []byte) *Command {
+	c.input = bytes.NewReader(input)
+	return c
+}
+
 // Sudo sets the command to run with sudo privileges.
 // If sudo authentication is required, the user will be prompted interactively.
 //
@@ -208,7 +485,15 @@ func (c *Command) Run() *Command { return c.execute() }
 // Example:
 //
 //	output := types.Cmd("echo", "hello").Stdout() // "hello\n"
-func (c *Command) Stdout() string { return c.execute().stdout }
+func (c *Command) Stdout() string { return string(c.StdoutBytes()) }
+
+// StdoutBytes executes the command and returns its stdout as bytes, useful for binary output.
+// It returns the captured bytes without copying, they must not be modified.
+//
+// Example:
+//
+//	archive := types.Cmd("tar", "-czf", "-", "dir").StdoutBytes()
+func (c *Command) StdoutBytes() []byte { return c.execute().output(c.stdout) }
 
 // StdoutErr executes the command and returns both stdout and any error.
 // This is useful when you need both the output and error information.
@@ -224,7 +509,15 @@ func (c *Command) StdoutErr() (string, error) { return c.Stdout(), c.Error() }
 // Example:
 //
 //	errMsg := types.Cmd("ls", "/nonexistent").Stderr()
-func (c *Command) Stderr() string { return c.execute().stderr }
+func (c *Command) Stderr() string { return string(c.StderrBytes()) }
+
+// StderrBytes executes the command and returns its stderr as bytes without copying,
+// they must not be modified.
+//
+// Example:
+//
+//	errOutput := types.Cmd("ls", "/nonexistent").StderrBytes()
+func (c *Command) StderrBytes() []byte { return c.execute().output(c.stderr) }
 
 // StderrErr executes the command and returns both stderr and any error.
 //
@@ -241,15 +534,16 @@ func (c *Command) StderrErr() (string, error) { return c.Stderr(), c.Error() }
 //	if err := types.Cmd("false").Error(); err != nil {
 //		// handle error
 //	}
-func (c *Command) Error() error { return c.execute().err }
+func (c *Command) Error() error { return c.redactErr(c.execute().err) }
 
 // StdoutStderr executes the command and returns both stdout and stderr concatenated.
 // This is useful when you need all output regardless of which stream it came from.
+// Use Combined to keep the order they were written in.
 //
 // Example:
 //
 //	allOutput := types.Cmd("ls", "/tmp", "/nonexistent").StdoutStderr()
-func (c *Command) StdoutStderr() string { return c.execute().stdout + c.execute().stderr }
+func (c *Command) StdoutStderr() string { return c.Stdout() + c.Stderr() }
 
 // WithContext sets the context for the command.
 // The context can be used for cancellation or timeout.
@@ -292,6 +586,25 @@ func (c *Command) WithDeadline(t time.Time) *Command {
 	return c
 }
 
+// ErrIdleTimeout is returned when a command is killed by IdleTimeout
+var ErrIdleTimeout = errors.New("idle timeout")
+
+// IdleTimeout kills the command if it doesn't write anything to stdout or stderr for
+// the given duration, the same way a cancelled context does. This detects hung commands
+// better than a total timeout. The error wraps ErrIdleTimeout to tell it apart from other
+// failures. IdleTimeout has no effect on Interactive commands, use InteractiveCapture.
+//
+// Example:
+//
+//	err := types.Cmd("apt-get", "install", "-y", "nginx").IdleTimeout(5 * time.Minute).Error()
+//	if errors.Is(err, types.ErrIdleTimeout) {
+//		// apt is hung
+//	}
+func (c *Command) IdleTimeout(d time.Duration) *Command {
+	c.idleTimeout = d
+	return c
+}
+
 // Dir sets the working directory for the command.
 // If not set, the command runs in the current working directory.
 //
@@ -360,10 +673,53 @@ func (c *Command) ExitCode() int {
 	return c.exitCode
 }
 
+// AllowExitCodes sets exit codes that are not considered failures, so Error returns nil
+// for them and they are not retried. ExitCode still returns the actual exit code.
+//
+// Example:
+//
+//	cmd := types.Cmd("grep", "needle", "haystack.txt").AllowExitCodes(1)
+//	if cmd.Error() == nil && cmd.ExitCode() == 1 {
+//		// no match
+//	}
+func (c *Command) AllowExitCodes(codes ...int) *Command {
+	c.allowedExitCodes = append(c.allowedExitCodes, codes...)
+	return c
+}
+
+// ExitCodeMeanings describes exit codes, the description is appended to the error
+// message when the command fails with one of them. The error still wraps *exec.ExitError.
+//
+// Example:
+//
+//	err := types.Cmd("my-tool").ExitCodeMeanings(map[int]string{3: "config not found"}).Error()
+//	// exit status 3: config not found
+func (c *Command) ExitCodeMeanings(meanings map[int]string) *Command {
+	if c.exitCodeMeanings == nil {
+		c.exitCodeMeanings = make(map[int]string)
+	}
+	for code, meaning := range meanings {
+		c.exitCodeMeanings[code] = meaning
+	}
+	return c
+}
+
+// ExitCodes applies the allowed exit codes and meanings of a profile,
+// see GrepExitCodes, DiffExitCodes, RsyncExitCodes and CurlExitCodes.
+//
+// Example:
+//
+//	err := types.Cmd("rsync", "-a", "src/", "dst/").ExitCodes(types.RsyncExitCodes()).Error()
+func (c *Command) ExitCodes(profile ExitCodeProfile) *Command {
+	return c.AllowExitCodes(profile.Allowed...).ExitCodeMeanings(profile.Meanings)
+}
+
 // Retry sets the number of retry attempts for the command.
 // If the command fails, it will be retried up to the specified number of times.
 // Use RetryWithBackoff for delays between retries.
 //
+// Retrying a sequence built with And, Or or Then runs both of its commands again.
+//
 // Example:
 //
 //	output := types.Cmd("curl", "http://example.com").Retry(3).Stdout()
@@ -394,18 +750,46 @@ func (c *Command) RetryWithBackoff(attempts int, delay time.Duration) *Command {
 //	cmd := types.Cmd("echo", "hello", "world")
 //	fmt.Println(cmd.String()) // "echo hello world"
 func (c *Command) String() string {
+	if c.seqOp != "" {
+		left, right := c.seqLeft.String(), c.seqRight.String()
+		// && and || bind tighter than ; and all operators are left associative
+		if c.seqOp != ";" && c.seqLeft.seqOp == ";" {
+			left = "(" + left + ")"
+		}
+		if c.seqRight.seqOp != "" {
+			right = "(" + right + ")"
+		}
+
+		if c.seqOp == ";" {
+			return left + "; " + right
+		}
+
+		return left + " " + c.seqOp + " " + right
+	}
+
+	if c.teeBranches != nil {
+		return c.teeString()
+	}
+
+	if c.xargs != nil {
+		return c.xargsString()
+	}
+
 	if c.cmd == "" {
 		return "<function>"
 	}
 
 	parts := []string{c.cmd}
+	if c.script != nil {
+		parts = append(parts, "<script>")
+	}
 	parts = append(parts, c.args...)
 
 	if c.useSudo {
 		parts = append([]string{"sudo"}, parts...)
 	}
 
-	return strings.Join(parts, " ")
+	return c.redact(strings.Join(parts, " "))
 }
 
 // StdoutTrimmed executes the command and returns stdout with leading/trailing whitespace removed.
@@ -421,11 +805,57 @@ func (c *Command) StdoutTrimmed() string {
 
 func (c *Command) execute() *Command {
 	if c.executed {
+		c.wait()
 		return c
 	}
 
 	c.executed = true
+	c.redactor = c.newRedactor()
+
+	c.propagate()
+
+	if c.cache != nil {
+		c.executeCached()
+		return c
+	}
 
+	c.executeRetries()
+
+	return c
+}
+
+// propagate sets the audit log and the limiter of the command on the commands of its
+// tree that have none
+func (c *Command) propagate() {
+	if c.audit == nil && c.limit == nil {
+		return
+	}
+
+	seen := map[*Command]bool{}
+
+	var visit func(cmd *Command)
+	visit = func(cmd *Command) {
+		if cmd == nil || seen[cmd] {
+			return
+		}
+		seen[cmd] = true
+
+		if cmd.audit == nil {
+			cmd.audit = c.audit
+		}
+		if cmd.limit == nil {
+			cmd.limit = c.limit
+		}
+
+		for _, child := range slices.Concat([]*Command{cmd.previous, cmd.seqLeft, cmd.seqRight}, cmd.teeBranches) {
+			visit(child)
+		}
+	}
+	visit(c)
+}
+
+// executeRetries executes the command until it succeeds or runs out of retry attempts
+func (c *Command) executeRetries() {
 	// Retry logic wrapper
 	maxAttempts := c.retryCount + 1
 	if maxAttempts < 1 {
@@ -437,6 +867,11 @@ func (c *Command) execute() *Command {
 			time.Sleep(c.retryDelay)
 		}
 
+		// the sides of a sequence keep their results, attempts run fresh copies of them
+		if attempt > 0 && c.seqOp != "" {
+			c.seqLeft, c.seqRight = c.seqLeft.clone(nil), c.seqRight.clone(nil)
+		}
+
 		c.executeOnce()
 
 		// If successful, break out of retry loop
@@ -444,66 +879,218 @@ func (c *Command) execute() *Command {
 			break
 		}
 	}
-
-	return c
 }
 
+// pipeStream selects which output streams of a command are piped to the next command
+type pipeStream int
+
+const (
+	// pipeStdout pipes stdout, this is the default
+	pipeStdout pipeStream = iota
+	// pipeStderr pipes stderr
+	pipeStderr
+	// pipeBoth pipes stdout and stderr merged
+	pipeBoth
+)
+
 // getStdoutPipe executes the command (if not already executed) and returns an io.Reader
 // that streams the stdout. This is used for efficient piping between commands.
 // For idempotency, if the command has already been executed, it returns a reader from
 // the cached stdout. Otherwise, it starts the command and sets up streaming.
-func (c *Command) getStdoutPipe() (io.Reader, error) {
+func (c *Command) getStdoutPipe() (io.Reader, error) { return c.getPipe(pipeStdout) }
+
+// getPipe is like getStdoutPipe for the selected stream. The streams that are not
+// piped are still captured and cached.
+func (c *Command) getPipe(stream pipeStream) (io.Reader, error) {
 	// If already executed, return reader from cached output
 	if c.executed {
-		if c.err != nil {
-			return nil, c.err
-		}
-		return strings.NewReader(c.stdout), nil
+		c.wait()
+		return c.cachedPipe(stream)
 	}
 
 	// Mark as executed to prevent re-execution
 	c.executed = true
+	c.redactor = c.newRedactor()
 
-	// Handle function commands - they need full input, so we execute normally
-	if c.cmdFn != nil {
+	// Handle commands that don't run a single process, they are executed normally
+	if c.cache != nil {
+		c.executeCached()
+		return c.cachedPipe(stream)
+	}
+
+	if !c.isProcess() {
 		c.executeOnce()
-		if c.err != nil {
-			return nil, c.err
-		}
-		return strings.NewReader(c.stdout), nil
+		return c.cachedPipe(stream)
 	}
 
-	// Handle piped input - if this command has a previous command,
-	// we need to stream from it too
-	if c.previous != nil {
-		// Get the pipe from the previous command
-		prevPipe, err := c.previous.getStdoutPipe()
-		if err != nil {
+	release := func() {}
+	if !c.limitedDownstream {
+		var err error
+		if release, err = c.acquireLimit(); err != nil {
 			c.err = err
 			return nil, err
 		}
-		c.input = prevPipe
 	}
 
-	// Build the command
+	command, err := c.buildCommand()
+	if err != nil {
+		release()
+		c.err = err
+		return nil, err
+	}
+
+	// Create a pipe to stream output while also capturing it for caching
+	pr, pw := io.Pipe()
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	// Stdout and stderr may write to the pipe concurrently when both are piped
+	piped := &lockedWriter{w: pw}
+	switch stream {
+	case pipeStdout:
+		command.Stdout = io.MultiWriter(c.record(OutputStdout, &stdoutBuf), piped)
+		command.Stderr = c.record(OutputStderr, &stderrBuf)
+	case pipeStderr:
+		command.Stdout = c.record(OutputStdout, &stdoutBuf)
+		command.Stderr = io.MultiWriter(c.record(OutputStderr, &stderrBuf), piped)
+	case pipeBoth:
+		command.Stdout = io.MultiWriter(c.record(OutputStdout, &stdoutBuf), piped)
+		command.Stderr = io.MultiWriter(c.record(OutputStderr, &stderrBuf), piped)
+	}
+
+	// Start the command
+	if err := c.startProcess(command); err != nil {
+		release()
+		c.err = err
+		c.writeAudit(command)
+		return nil, c.err
+	}
+
+	go func() {
+		// Wait for the command to finish and output to be copied
+		waitErr := c.waitProcess(command)
+		release()
+
+		c.stdout = stdoutBuf.Bytes()
+		c.stderr = stderrBuf.Bytes()
+		c.setErr(waitErr)
+		c.writeAudit(command)
+
+		// Close the pipe writer
+		if c.err != nil {
+			pw.CloseWithError(c.err)
+		} else {
+			pw.Close()
+		}
+	}()
+
+	return pr, nil
+}
+
+// isProcess reports whether the command runs a single system process,
+// as opposed to functions, sequences, tees, fan-outs and parallel stages
+func (c *Command) isProcess() bool {
+	return c.cmdFn == nil && c.seqOp == "" && c.teeBranches == nil && c.xargs == nil && c.parallel == nil
+}
+
+// cachedPipe returns a reader of the captured stream, or the command error. The stderr
+// of a command that exited with an error is still piped, failing with the error at the
+// end like the pipe of a running command, since it usually tells why it failed.
+func (c *Command) cachedPipe(stream pipeStream) (io.Reader, error) {
+	reader := bytes.NewReader(c.cachedStream(stream))
+	if c.err == nil {
+		return reader, nil
+	}
+
+	var exitErr *exec.ExitError
+	if stream == pipeStdout || !errors.As(c.err, &exitErr) {
+		return nil, c.err
+	}
+
+	return io.MultiReader(reader, failingReader{err: c.err}), nil
+}
+
+// failingReader fails all reads with err
+type failingReader struct{ err error }
+
+func (f failingReader) Read([]byte) (int, error) { return 0, f.err }
+
+// cachedStream returns the captured output selected by stream
+func (c *Command) cachedStream(stream pipeStream) []byte {
+	switch stream {
+	case pipeStderr:
+		return c.stderr
+	case pipeBoth:
+		return slices.Concat(c.stdout, c.stderr)
+	default:
+		return c.stdout
+	}
+}
+
+// lockedWriter serializes writes from multiple goroutines
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Write(p)
+}
+
+// buildCommand creates the exec.Cmd for a system command with its context, sudo,
+// working directory, environment and stdin. Stdin streams from the previous
+// command in a pipeline if there is one.
+func (c *Command) buildCommand() (*exec.Cmd, error) {
 	var command *exec.Cmd
+	c.timeline = &timeline{}
+	c.started = time.Time{}
+
+	// Use context if provided
 	ctx := c.ctx
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
+	// The idle watchdog kills the command by cancelling its context
+	c.idle = nil
+	if c.idleTimeout > 0 && (!c.interactive || c.captureInteractive) {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		c.idle = &idleWatchdog{timeout: c.idleTimeout, cancel: cancel}
+	}
+
+	var env map[string]string
+	if c.customEnv() || c.expandEnv {
+		var err error
+		if env, err = c.environ(); err != nil {
+			return nil, err
+		}
+	}
+
+	args, err := c.expandArgs(env)
+	if err != nil {
+		return nil, err
+	}
+
 	if c.useSudo {
-		// Check if sudo is already authenticated
+		// Check if sudo is already authenticated (non-interactive)
 		if err := Cmd("sudo", "-n", "true").Error(); err != nil {
-			// Request authentication interactively
+			// Not authenticated, request authentication interactively
 			if err := Cmd("sudo", "-v").Interactive().Error(); err != nil {
-				c.err = err
 				return nil, err
 			}
 		}
-		command = exec.CommandContext(ctx, "sudo", append([]string{c.cmd}, c.args...)...)
+
+		command = exec.CommandContext(ctx, "sudo", append([]string{c.cmd}, args...)...)
 	} else {
-		command = exec.CommandContext(ctx, c.cmd, c.args...)
+		command = exec.CommandContext(ctx, c.cmd, args...)
+	}
+
+	// Find the executable in the PATH set with Env like a shell does, instead of the current PATH
+	if path, ok := env["PATH"]; ok && path != os.Getenv("PATH") && !strings.ContainsRune(command.Args[0], filepath.Separator) {
+		command.Path, command.Err = c.lookPath(command.Args[0])
 	}
 
 	// Set working directory
@@ -511,16 +1098,11 @@ func (c *Command) getStdoutPipe() (io.Reader, error) {
 		command.Dir = c.dir
 	}
 
-	// Set environment variables
-	if c.clearEnv {
+	// Set environment variables, sorted with a single entry per key
+	if c.customEnv() {
 		command.Env = []string{}
-	}
-	if c.env != nil {
-		if !c.clearEnv {
-			command.Env = os.Environ()
-		}
-		for k, v := range c.env {
-			command.Env = append(command.Env, fmt.Sprintf("%s=%s", k, v))
+		for _, key := range slices.Sorted(maps.Keys(env)) {
+			command.Env = append(command.Env, key+"="+env[key])
 		}
 	}
 
@@ -531,171 +1113,309 @@ func (c *Command) getStdoutPipe() (io.Reader, error) {
 		command.Stdin = os.Stdin
 	}
 
-	// Get stdout pipe
-	stdoutPipe, err := command.StdoutPipe()
-	if err != nil {
-		c.err = err
-		return nil, err
+	if c.previous != nil {
+		// Stream output from previous command instead of reading all at once,
+		// it runs under the limiter slot of this command
+		c.previous.limitedDownstream = true
+		pipe, err := c.previous.getPipe(c.pipeFrom)
+		if err != nil {
+			return nil, err
+		}
+		command.Stdin = pipe
 	}
 
-	// Capture stderr separately
-	var stderrBuf strings.Builder
-	command.Stderr = &stderrBuf
+	return command, nil
+}
 
-	// Start the command
-	if err := command.Start(); err != nil {
-		c.err = err
-		return nil, err
+// setErr sets the command error and extracts the exit code from it
+// Exit codes in allowedExitCodes clear the error, others are described by exitCodeMeanings.
+// Script errors are located in the script.
+func (c *Command) setErr(err error) {
+	c.err = err
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			c.exitCode = status.ExitStatus()
+			if c.forwardSignals && status.Signaled() {
+				// like a shell, a command killed by a signal exits with 128 + the signal number
+				c.exitCode = 128 + int(status.Signal())
+			}
+			if slices.Contains(c.allowedExitCodes, c.exitCode) {
+				c.err = nil
+			} else if meaning, ok := c.exitCodeMeanings[c.exitCode]; ok {
+				c.err = fmt.Errorf("%w: %s", err, meaning)
+			}
+		}
 	}
 
-	// Create a pipe to stream stdout while also capturing it for caching
-	pr, pw := io.Pipe()
+	if c.script != nil {
+		c.err = c.scriptError(c.err, c.stderr)
+	}
+}
 
-	// Start a goroutine to tee the output: write to both the pipe and our buffer
-	go func() {
-		var stdoutBuf strings.Builder
-		// Use TeeReader to read from stdoutPipe and write to both the buffer and the pipe
-		teeReader := io.TeeReader(stdoutPipe, &stdoutBuf)
-		
-		// Copy everything through
-		_, copyErr := io.Copy(pw, teeReader)
-		
-		// Wait for the command to finish
-		waitErr := command.Wait()
-		
-		// Store stderr
-		c.stderr = stderrBuf.String()
-		
-		// Store stdout from the buffer
-		c.stdout = stdoutBuf.String()
-		
-		// Extract exit code
-		if waitErr != nil {
-			c.err = waitErr
-			if exitErr, ok := waitErr.(*exec.ExitError); ok {
-				if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-					c.exitCode = status.ExitStatus()
-				}
-			}
-		}
-		
-		// Close the pipe writer
-		if copyErr != nil {
-			pw.CloseWithError(copyErr)
-		} else if waitErr != nil {
-			pw.CloseWithError(waitErr)
-		} else {
-			pw.Close()
-		}
-	}()
+// executeSequence runs the left command then the right one if the operator allows it
+func (c *Command) executeSequence() {
+	c.ran = c.ran[:0]
 
-	return pr, nil
+	left := c.seqLeft.execute()
+	c.ran = append(c.ran, left.Ran()...)
+	c.stdout, c.stderr = left.stdout, left.stderr
+	c.err, c.exitCode = left.err, left.exitCode
+
+	switch {
+	case c.seqOp == "&&" && left.err != nil:
+		return
+	case c.seqOp == "||" && left.err == nil:
+		return
+	}
+
+	right := c.seqRight.execute()
+	c.ran = append(c.ran, right.Ran()...)
+	c.stdout = slices.Concat(c.stdout, right.stdout)
+	c.stderr = slices.Concat(c.stderr, right.stderr)
+	c.err, c.exitCode = right.err, right.exitCode
 }
 
 func (c *Command) executeOnce() {
+	if c.seqOp != "" {
+		c.executeSequence()
+		return
+	}
+
+	if c.teeBranches != nil {
+		c.executeTee()
+		return
+	}
+
+	if c.xargs != nil {
+		c.executeXargs()
+		return
+	}
+
+	if c.parallel != nil {
+		c.executeParallel()
+		return
+	}
 
 	if c.cmdFn != nil {
 		// Execute previous command first or read from input
-		var stdin string
+		var stdin []byte
 		if c.previous != nil {
-			stdin, c.err = c.previous.StdoutErr()
-			if c.err != nil {
+			c.previous.execute()
+			if c.err = c.previous.err; c.err != nil {
 				return
 			}
+			stdin = c.previous.cachedStream(c.pipeFrom)
 		} else if c.input != nil {
 			// Read from input reader
-			buf := new(strings.Builder)
-			_, c.err = io.Copy(buf, c.input)
+			stdin, c.err = io.ReadAll(c.input)
 			if c.err != nil {
 				return
 			}
-			stdin = buf.String()
 		}
 
 		c.stdout, c.stderr, c.err = c.cmdFn(stdin)
 		return
 	}
 
-	// Build command with sudo if needed
-	var command *exec.Cmd
-
-	// Use context if provided
-	ctx := c.ctx
-	if ctx == nil {
-		ctx = context.Background()
+	release, err := c.acquireLimit()
+	if err != nil {
+		c.err = err
+		return
 	}
+	defer release()
 
-	if c.useSudo {
-		// Check if sudo is already authenticated (non-interactive)
-		if err := Cmd("sudo", "-n", "true").Error(); err != nil {
-			// Not authenticated, request authentication interactively
-			if err := Cmd("sudo", "-v").Interactive().Error(); err != nil {
-				c.err = err
-				return
-			}
-		}
+	command, err := c.buildCommand()
+	if err != nil {
+		c.err = err
+		return
+	}
 
-		command = exec.CommandContext(ctx, "sudo", append([]string{c.cmd}, c.args...)...)
+	// Set stdout/stderr based on mode
+	if c.interactive && (c.captureInteractive || c.recordStdin) {
+		c.runInteractiveCapture(command)
+	} else if c.interactive {
+		command.Stdout = os.Stdout
+		command.Stderr = os.Stderr
+		c.setErr(c.runProcess(command))
 	} else {
-		command = exec.CommandContext(ctx, c.cmd, c.args...)
+		// Capture stdout and stderr separately
+		var stdoutBuf, stderrBuf bytes.Buffer
+		command.Stdout = c.record(OutputStdout, &stdoutBuf)
+		command.Stderr = c.record(OutputStderr, &stderrBuf)
+		err := c.runProcess(command)
+		c.stdout = stdoutBuf.Bytes()
+		c.stderr = stderrBuf.Bytes()
+		c.setErr(err)
 	}
 
-	// Set working directory
-	if c.dir != "" {
-		command.Dir = c.dir
-	}
+	c.writeAudit(command)
+}
 
-	// Set environment variables
-	if c.clearEnv {
-		command.Env = []string{}
+// runInteractiveCapture runs an interactive command, copying its output to the terminal
+// and to the captured buffers and recording the terminal input if requested
+func (c *Command) runInteractiveCapture(command *exec.Cmd) {
+	var stdoutBuf, stderrBuf bytes.Buffer
+	if c.captureInteractive {
+		command.Stdout = io.MultiWriter(os.Stdout, c.record(OutputStdout, &stdoutBuf))
+		command.Stderr = io.MultiWriter(os.Stderr, c.record(OutputStderr, &stderrBuf))
+	} else {
+		command.Stdout = os.Stdout
+		command.Stderr = os.Stderr
 	}
-	if c.env != nil {
-		if !c.clearEnv {
-			command.Env = os.Environ()
+
+	var recorded *lockedWriter
+	// stopRecording stops copying the terminal input once the command exited
+	stopRecording := func() {}
+	if c.recordStdin && command.Stdin == os.Stdin {
+		r, w, err := os.Pipe()
+		if err != nil {
+			c.err = err
+			return
 		}
-		for k, v := range c.env {
-			command.Env = append(command.Env, fmt.Sprintf("%s=%s", k, v))
+
+		recorded = &lockedWriter{w: &strings.Builder{}}
+		terminal := newTerminalInput(os.Stdin)
+		command.Stdin = r
+		reading := make(chan struct{})
+		stopRecording = func() {
+			r.Close()
+			terminal.stop(reading)
 		}
+		go func() {
+			defer close(reading)
+			defer w.Close()
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := terminal.Read(buf)
+				if n > 0 {
+					recorded.Write(buf[:n])
+					if _, err := w.Write(buf[:n]); err != nil {
+						return
+					}
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
 	}
 
-	// Set stdin
-	if c.input != nil {
-		command.Stdin = c.input
-	} else if c.interactive {
-		command.Stdin = os.Stdin
+	err := c.runProcess(command)
+	stopRecording()
+	c.stdout = stdoutBuf.Bytes()
+	c.stderr = stderrBuf.Bytes()
+	if recorded != nil {
+		recorded.mu.Lock()
+		c.stdin = recorded.w.(*strings.Builder).String()
+		recorded.mu.Unlock()
 	}
+	c.setErr(err)
+}
 
-	if c.previous != nil {
-		// Stream stdout from previous command instead of reading all at once
-		stdoutPipe, err := c.previous.getStdoutPipe()
-		if err != nil {
-			c.err = err
-			return
+// runProcess starts command and waits for it to exit
+func (c *Command) runProcess(command *exec.Cmd) error {
+	if err := c.startProcess(command); err != nil {
+		return err
+	}
+
+	return c.waitProcess(command)
+}
+
+// startProcess starts command, watching its output for inactivity if IdleTimeout is set
+// and forwarding signals to it if ForwardSignals is set
+func (c *Command) startProcess(command *exec.Cmd) error {
+	if c.script != nil {
+		if err := c.prepareScript(command); err != nil {
+			return err
 		}
-		command.Stdin = stdoutPipe
 	}
 
-	// Set stdout/stderr based on mode
-	if c.interactive {
-		command.Stdout = os.Stdout
-		command.Stderr = os.Stderr
-		c.err = command.Run()
-	} else {
-		// Capture stdout and stderr separately
-		var stdoutBuf, stderrBuf strings.Builder
-		command.Stdout = &stdoutBuf
-		command.Stderr = &stderrBuf
-		c.err = command.Run()
-		c.stdout = stdoutBuf.String()
-		c.stderr = stderrBuf.String()
-	}
-
-	// Extract exit code from error
-	if c.err != nil {
-		if exitErr, ok := c.err.(*exec.ExitError); ok {
-			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-				c.exitCode = status.ExitStatus()
-			}
+	c.signals = nil
+	if c.forwardSignals {
+		c.signals = prepareSignals(command)
+	}
+
+	if c.idle != nil {
+		command.Stdout = &activityWriter{w: command.Stdout, idle: c.idle}
+		command.Stderr = &activityWriter{w: command.Stderr, idle: c.idle}
+		c.idle.timer = time.AfterFunc(c.idle.timeout, c.idle.fire)
+	}
+
+	c.started = time.Now()
+	if err := command.Start(); err != nil {
+		if c.idle != nil {
+			c.idle.stop()
 		}
+		if c.signals != nil {
+			c.signals.stop()
+		}
+		if c.script != nil {
+			c.cleanupScript()
+		}
+		return err
+	}
+
+	if c.signals != nil {
+		c.signals.start(command.Process)
+	}
+
+	return nil
+}
+
+// waitProcess waits for a command started by startProcess
+func (c *Command) waitProcess(command *exec.Cmd) error {
+	err := command.Wait()
+	if c.signals != nil {
+		c.signals.stop()
+	}
+	if c.script != nil {
+		c.cleanupScript()
+	}
+
+	if c.idle == nil {
+		return err
 	}
+
+	c.idle.stop()
+	if c.idle.fired.Load() {
+		return fmt.Errorf("%w: no output for %s: %w", ErrIdleTimeout, c.idle.timeout, err)
+	}
+
+	return err
+}
+
+// idleWatchdog cancels a command context when its timer fires
+type idleWatchdog struct {
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	fired   atomic.Bool
+}
+
+func (w *idleWatchdog) fire() {
+	w.fired.Store(true)
+	w.cancel()
+}
+
+func (w *idleWatchdog) stop() {
+	w.timer.Stop()
+	w.cancel()
+}
+
+// activityWriter resets the idle watchdog timer on every write
+type activityWriter struct {
+	w    io.Writer
+	idle *idleWatchdog
+}
+
+func (a *activityWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		a.idle.timer.Reset(a.idle.timeout)
+	}
+
+	if a.w == nil {
+		return len(p), nil
+	}
+
+	return a.w.Write(p)
 }
@@ -0,0 +1,326 @@
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditLog appends a JSON Lines record for every process executed by the commands using
+// it. It's safe for concurrent use by commands of the same program, rotation isn't
+// coordinated between programs sharing a file.
+//
+// Example:
+//
+//	audit := &types.AuditLog{Path: "/var/log/ops/commands.jsonl", MaxSize: 10 << 20, MaxFiles: 5}
+//	err := types.Cmd("kubectl", "apply", "-f", "deploy.yaml").Audit(audit).Error()
+type AuditLog struct {
+	// Path is the log file, it's created with its directory when the first record is written
+	Path string
+	// MaxSize rotates the file before a record would make it larger than this size in
+	// bytes. Rotated files are named Path.<UTC time>. Zero means no rotation
+	MaxSize int64
+	// MaxFiles is the number of rotated files kept, older ones are removed. Zero keeps them all
+	MaxFiles int
+
+	mu sync.Mutex
+}
+
+// AuditRecord is an execution of a process written to an AuditLog
+type AuditRecord struct {
+	// Time is when the process was started
+	Time time.Time `json:"time"`
+	User string    `json:"user"`
+	Host string    `json:"host"`
+	// Args is the program and its arguments as executed, including sudo, with secrets redacted
+	Args []string `json:"args"`
+	Dir  string   `json:"dir"`
+	// ExitCode is -1 when the process couldn't be started
+	ExitCode   int           `json:"exit_code"`
+	Duration   time.Duration `json:"duration_ns"`
+	StdoutSize int           `json:"stdout_size"`
+	StderrSize int           `json:"stderr_size"`
+	// CorrelationID is set with WithCorrelationID on the command context
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// Error is the redacted command error
+	Error string `json:"error,omitempty"`
+}
+
+// AuditQuery selects records of AuditLog.Query, zero fields match all records
+type AuditQuery struct {
+	// Since and Until bound the record start time
+	Since time.Time
+	Until time.Time
+	// CorrelationID matches records of a correlation ID
+	CorrelationID string
+	// Program matches the first argument or its base name, like "git" for /usr/bin/git
+	Program string
+	// Failed matches records with a non-zero exit code or an error
+	Failed bool
+}
+
+// Audit writes a record to log for every process executed by the command, including
+// piped, sequenced, tee, fan-out and parallel commands. A record that can't be written
+// makes the command fail. Results served from Cache aren't recorded.
+//
+// Example:
+//
+//	ctx := types.WithCorrelationID(context.Background(), requestID)
+//	err := types.Cmd("git", "push").WithContext(ctx).Audit(audit).Error()
+func (c *Command) Audit(log *AuditLog) *Command {
+	c.audit = log
+	return c
+}
+
+// correlationIDKey is the context key of the correlation ID
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a context carrying id, audit records of commands using the
+// context include it to relate them to a request or a job.
+//
+// Example:
+//
+//	ctx := types.WithCorrelationID(r.Context(), r.Header.Get("X-Request-ID"))
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation ID of ctx set with WithCorrelationID, or ""
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// writeAudit writes the record of the process that was started, or failed to start, for command
+func (c *Command) writeAudit(command *exec.Cmd) {
+	if c.audit == nil || c.started.IsZero() {
+		return
+	}
+
+	record := AuditRecord{
+		Time:       c.started,
+		Args:       make([]string, len(command.Args)),
+		Dir:        command.Dir,
+		ExitCode:   -1,
+		Duration:   time.Since(c.started),
+		StdoutSize: len(c.stdout),
+		StderrSize: len(c.stderr),
+	}
+	record.User, record.Host = auditIdentity()
+
+	for i, arg := range command.Args {
+		record.Args[i] = c.redact(arg)
+	}
+
+	if record.Dir == "" {
+		record.Dir, _ = os.Getwd()
+	}
+
+	if command.ProcessState != nil {
+		record.ExitCode = 0
+		if !command.ProcessState.Success() {
+			record.ExitCode = c.exitCode
+		}
+	}
+
+	if c.ctx != nil {
+		record.CorrelationID = CorrelationID(c.ctx)
+	}
+
+	if c.err != nil {
+		record.Error = c.redactErr(c.err).Error()
+	}
+
+	if err := c.audit.append(record); err != nil {
+		c.err = errors.Join(c.err, fmt.Errorf("audit log: %w", err))
+	}
+}
+
+// auditIdentity returns the user and host name of the program
+var auditIdentity = sync.OnceValues(func() (string, string) {
+	name := os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	} else if name == "" {
+		name = os.Getenv("USERNAME")
+	}
+
+	host, _ := os.Hostname()
+
+	return name, host
+})
+
+// append writes a record to the log, rotating it first if needed
+func (l *AuditLog) append(record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0o755); err != nil {
+		return err
+	}
+
+	if l.MaxSize > 0 {
+		if info, err := os.Stat(l.Path); err == nil && info.Size() > 0 && info.Size()+int64(len(line)) > l.MaxSize {
+			if err := l.rotate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	file, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// auditRotationFormat is the time suffix of rotated files, it sorts in rotation order
+const auditRotationFormat = "20060102T150405.000000000"
+
+// rotate renames the log file with the current time and removes the oldest rotated files
+func (l *AuditLog) rotate() error {
+	if err := os.Rename(l.Path, l.Path+"."+time.Now().UTC().Format(auditRotationFormat)); err != nil {
+		return err
+	}
+
+	if l.MaxFiles <= 0 {
+		return nil
+	}
+
+	rotated, err := l.rotatedFiles()
+	if err != nil {
+		return err
+	}
+
+	for len(rotated) > l.MaxFiles {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+
+	return nil
+}
+
+// rotatedFiles returns the rotated files of the log from the oldest to the newest
+func (l *AuditLog) rotatedFiles() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(l.Path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(l.Path) + "."
+	var rotated []string
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok {
+			continue
+		}
+		if _, err := time.Parse(auditRotationFormat, suffix); err == nil {
+			rotated = append(rotated, filepath.Join(filepath.Dir(l.Path), entry.Name()))
+		}
+	}
+	slices.Sort(rotated)
+
+	return rotated, nil
+}
+
+// Query returns the records matching q from the rotated files and the current file, in
+// the order they were written.
+//
+// Example:
+//
+//	failures, err := audit.Query(types.AuditQuery{Since: time.Now().Add(-24 * time.Hour), Program: "kubectl", Failed: true})
+//	for _, record := range failures {
+//		fmt.Println(record.Time, strings.Join(record.Args, " "), record.Error)
+//	}
+func (l *AuditLog) Query(q AuditQuery) ([]AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files, err := l.rotatedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []AuditRecord{}
+	for _, path := range append(files, l.Path) {
+		file, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		reader := bufio.NewReader(file)
+		for number := 1; ; number++ {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				var record AuditRecord
+				if err := json.Unmarshal(line, &record); err != nil {
+					file.Close()
+					return nil, fmt.Errorf("%s:%d: %w", path, number, err)
+				}
+				if q.matches(record) {
+					records = append(records, record)
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+		file.Close()
+	}
+
+	return records, nil
+}
+
+// matches reports whether record is selected by the query
+func (q AuditQuery) matches(record AuditRecord) bool {
+	switch {
+	case !q.Since.IsZero() && record.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && record.Time.After(q.Until):
+		return false
+	case q.CorrelationID != "" && record.CorrelationID != q.CorrelationID:
+		return false
+	case q.Failed && record.ExitCode == 0 && record.Error == "":
+		return false
+	case q.Program != "":
+		return len(record.Args) > 0 && (record.Args[0] == q.Program || filepath.Base(record.Args[0]) == q.Program)
+	}
+
+	return true
+}
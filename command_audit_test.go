@@ -0,0 +1,155 @@
+package types
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudit(t *testing.T) {
+	t.Run("records executed processes", func(t *testing.T) {
+		audit := &AuditLog{Path: filepath.Join(t.TempDir(), "logs", "audit.jsonl")}
+		ctx := WithCorrelationID(context.Background(), "request-1")
+		dir := t.TempDir()
+
+		start := time.Now()
+		cmd := Cmd("sh", "-c", "echo hello; echo oops >&2; exit 3").Dir(dir).WithContext(ctx).Audit(audit)
+		require.Error(t, cmd.Error())
+
+		records, err := audit.Query(AuditQuery{})
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+
+		record := records[0]
+		require.Equal(t, []string{"sh", "-c", "echo hello; echo oops >&2; exit 3"}, record.Args)
+		require.Equal(t, dir, record.Dir)
+		require.Equal(t, 3, record.ExitCode)
+		require.Equal(t, 6, record.StdoutSize)
+		require.Equal(t, 5, record.StderrSize)
+		require.Equal(t, "request-1", record.CorrelationID)
+		require.Equal(t, "exit status 3", record.Error)
+		require.NotEmpty(t, record.User)
+		require.NotEmpty(t, record.Host)
+		require.False(t, record.Time.Before(start.Truncate(time.Second)))
+		require.Positive(t, record.Duration)
+	})
+
+	t.Run("records every process of a pipeline", func(t *testing.T) {
+		audit := &AuditLog{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+
+		cmd := Cmd("echo", "b\na").Pipe("sort").PipeFn(func(stdin string) (string, string, error) {
+			return strings.ToUpper(stdin), "", nil
+		}).Pipe("cat").Audit(audit)
+		require.Equal(t, "A\nB\n", cmd.Stdout())
+
+		seq := Cmd("true").And(Cmd("false")).Or(Cmd("echo", "recovered")).Audit(audit)
+		require.NoError(t, seq.Error())
+
+		records, err := audit.Query(AuditQuery{})
+		require.NoError(t, err)
+
+		var programs []string
+		for _, record := range records {
+			programs = append(programs, record.Args[0])
+		}
+		require.ElementsMatch(t, []string{"echo", "sort", "cat", "true", "false", "echo"}, programs)
+	})
+
+	t.Run("redacts secrets", func(t *testing.T) {
+		audit := &AuditLog{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+		token := "audit-secret-token"
+
+		Cmd("sh", "-c", "exit 1", "--token="+token).Secret(token).Audit(audit).Run()
+
+		content, err := os.ReadFile(audit.Path)
+		require.NoError(t, err)
+		require.NotContains(t, string(content), token)
+
+		records, err := audit.Query(AuditQuery{})
+		require.NoError(t, err)
+		require.Equal(t, "--token=***", records[0].Args[3])
+	})
+
+	t.Run("records processes failing to start", func(t *testing.T) {
+		audit := &AuditLog{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+
+		require.Error(t, Cmd("types-missing-program").Audit(audit).Error())
+
+		records, err := audit.Query(AuditQuery{Failed: true})
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		require.Equal(t, -1, records[0].ExitCode)
+		require.Contains(t, records[0].Error, "types-missing-program")
+	})
+
+	t.Run("write failures fail the command", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(file, nil, 0o644))
+
+		cmd := Cmd("true").Audit(&AuditLog{Path: filepath.Join(file, "audit.jsonl")})
+		require.ErrorContains(t, cmd.Error(), "audit log")
+	})
+
+	t.Run("rotates by size", func(t *testing.T) {
+		audit := &AuditLog{Path: filepath.Join(t.TempDir(), "audit.jsonl"), MaxSize: 1, MaxFiles: 2}
+
+		for i := range 4 {
+			require.NoError(t, Cmd("echo", strings.Repeat("x", i)).Audit(audit).Error())
+		}
+
+		rotated, err := audit.rotatedFiles()
+		require.NoError(t, err)
+		require.Len(t, rotated, 2)
+
+		records, err := audit.Query(AuditQuery{})
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		for i, record := range records {
+			require.Equal(t, strings.Repeat("x", i+1), record.Args[1])
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		audit := &AuditLog{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+		base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, record := range []AuditRecord{
+			{Time: base, Args: []string{"/usr/bin/git", "status"}, CorrelationID: "a"},
+			{Time: base.Add(time.Hour), Args: []string{"git", "push"}, ExitCode: 1, CorrelationID: "b"},
+			{Time: base.Add(2 * time.Hour), Args: []string{"docker", "ps"}, Error: "signal: killed", ExitCode: -1, CorrelationID: "b"},
+		} {
+			require.NoError(t, audit.append(record))
+		}
+
+		tests := []struct {
+			name     string
+			query    AuditQuery
+			expected []string
+		}{
+			{name: "all", query: AuditQuery{}, expected: []string{"status", "push", "ps"}},
+			{name: "since", query: AuditQuery{Since: base.Add(time.Hour)}, expected: []string{"push", "ps"}},
+			{name: "until", query: AuditQuery{Until: base.Add(time.Hour)}, expected: []string{"status", "push"}},
+			{name: "correlation id", query: AuditQuery{CorrelationID: "b"}, expected: []string{"push", "ps"}},
+			{name: "program", query: AuditQuery{Program: "git"}, expected: []string{"status", "push"}},
+			{name: "failed", query: AuditQuery{Failed: true}, expected: []string{"push", "ps"}},
+			{name: "combined", query: AuditQuery{Program: "git", Failed: true}, expected: []string{"push"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				records, err := audit.Query(tt.query)
+				require.NoError(t, err)
+
+				var args []string
+				for _, record := range records {
+					args = append(args, record.Args[1])
+				}
+				require.Equal(t, tt.expected, args)
+			})
+		}
+	})
+}
@@ -0,0 +1,352 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CacheStore is a directory holding cached command results, shared by all processes using it
+type CacheStore struct {
+	// Dir is the cache directory, created when the first result is stored
+	Dir string
+	// TTL is how long a result is served after it was stored. Zero means forever
+	TTL time.Duration
+	// MaxSize is the total size in bytes of stored results, the least recently used
+	// results are removed to stay under it. Zero means no limit
+	MaxSize int64
+}
+
+// CacheKey selects what a cached result depends on in addition to the command itself
+type CacheKey struct {
+	// Env are names of environment variables included in the key
+	Env []string
+	// Files are paths included in the key by modification time and size
+	Files []string
+	// HashFiles are paths included in the key by content, slower than Files but
+	// unaffected by touching files
+	HashFiles []string
+	// Values are included in the key as is, like the variables functions depend on
+	Values []string
+}
+
+// commandCache is the cache store and key options of a command
+type commandCache struct {
+	store *CacheStore
+	key   CacheKey
+}
+
+// cacheEntry is a stored command result
+type cacheEntry struct {
+	// Created is when the result was stored, the file modification time is when it was last
+	// used. It's the first field so eviction reads it without decoding the outputs.
+	Created  time.Time `json:"created"`
+	Stdout   []byte    `json:"stdout"`
+	Stderr   []byte    `json:"stderr"`
+	ExitCode int       `json:"exit_code"`
+}
+
+// Cache serves the command results from store when the command already ran with the
+// same key, instead of running it again. The key hashes the arguments after expansion,
+// working directory, sudo, environment set with Env, env files, restricted inherited
+// environment and stdin of every command in the pipeline, and the inherited environment
+// variables and files selected in key. Functions and Xargs templates are identified by
+// their name, like main.main.func1, and position in the pipeline, not by the variables
+// they capture, add those to the key Values.
+//
+// Only runs that finished without error are stored, with their stdout, stderr and exit
+// code. Cache failures, like an unwritable directory, never fail the command, it just
+// runs without caching.
+//
+// Example:
+//
+//	store := &types.CacheStore{Dir: ".cache/commands", TTL: time.Hour, MaxSize: 100 << 20}
+//	packages := types.Cmd("go", "list", "-json", "./...").
+//		Cache(store, types.CacheKey{Env: []string{"GOOS", "GOARCH"}, HashFiles: []string{"go.mod", "go.sum"}}).
+//		Stdout()
+func (c *Command) Cache(store *CacheStore, key CacheKey) *Command {
+	c.cache = &commandCache{store: store, key: key}
+	return c
+}
+
+// executeCached serves the command results from the cache or executes it and stores them
+func (c *Command) executeCached() {
+	key, err := c.cacheKey()
+	if err != nil {
+		c.executeRetries()
+		return
+	}
+
+	if entry, ok := c.cache.store.load(key); ok {
+		c.stdout, c.stderr, c.exitCode = entry.Stdout, entry.Stderr, entry.ExitCode
+		return
+	}
+
+	c.executeRetries()
+	if c.err == nil {
+		c.cache.store.save(key, cacheEntry{Stdout: c.stdout, Stderr: c.stderr, ExitCode: c.exitCode, Created: time.Now()})
+	}
+}
+
+// cacheKey hashes the pipeline and the selected environment variables and files
+func (c *Command) cacheKey() (string, error) {
+	h := sha256.New()
+
+	var visit func(cmd *Command) error
+	visit = func(cmd *Command) error {
+		if cmd == nil {
+			return nil
+		}
+
+		for _, child := range slices.Concat([]*Command{cmd.previous, cmd.seqLeft, cmd.seqRight}, cmd.teeBranches) {
+			if err := visit(child); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(h, "cmd %q %q dir %q sudo %t from %d function %t %q\n", cmd.cmd, cmd.args, cmd.dir, cmd.useSudo, cmd.pipeFrom, cmd.cmdFn != nil, cmd.fnName)
+		if cmd.xargs != nil {
+			fmt.Fprintf(h, "xargs %q %+v\n", cmd.xargs.name, cmd.xargs.opts)
+		}
+		if cmd.parallel != nil {
+			fmt.Fprintf(h, "parallel %+v\n", cmd.parallel.opts)
+		}
+		fmt.Fprintf(h, "clear env %t inherit %q %q\n", cmd.clearEnv, cmd.inheritEnv, cmd.inheritEnvPrefix)
+
+		var env map[string]string
+		if cmd.customEnv() || cmd.expandEnv {
+			var err error
+			if env, err = cmd.environ(); err != nil {
+				return err
+			}
+
+			// variables inherited unchanged are keyed with CacheKey.Env, unless the
+			// command restricts the inherited environment
+			inheritsAll := !cmd.clearEnv && cmd.inheritEnv == nil && cmd.inheritEnvPrefix == nil
+			for _, name := range slices.Sorted(maps.Keys(env)) {
+				if value, ok := os.LookupEnv(name); inheritsAll && ok && value == env[name] {
+					continue
+				}
+				fmt.Fprintf(h, "set env %q %q\n", name, env[name])
+			}
+
+			for _, path := range cmd.envFiles {
+				if err := hashFile(h, path, cmd.resolvePath(path)); err != nil {
+					return err
+				}
+			}
+		}
+
+		// the expanded arguments depend on the environment and the files matching globs
+		args, err := cmd.expandArgs(env)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "args %q\n", args)
+		if cmd.script != nil {
+			fmt.Fprintf(h, "script %q strict %t\n", cmd.script.body, cmd.script.strict)
+		}
+
+		if cmd.input != nil {
+			input, err := io.ReadAll(cmd.input)
+			if err != nil {
+				return err
+			}
+			cmd.input = bytes.NewReader(input)
+			fmt.Fprintf(h, "input %d\n", len(input))
+			h.Write(input)
+		}
+
+		return nil
+	}
+	if err := visit(c); err != nil {
+		return "", err
+	}
+
+	for _, name := range c.cache.key.Env {
+		value, ok := c.env[name]
+		if !ok && !c.clearEnv {
+			value, ok = os.LookupEnv(name)
+		}
+		fmt.Fprintf(h, "env %q %t %q\n", name, ok, value)
+	}
+
+	for _, path := range c.cache.key.Files {
+		info, err := os.Stat(c.resolvePath(path))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "file %q %d %d\n", path, info.ModTime().UnixNano(), info.Size())
+	}
+
+	for _, path := range c.cache.key.HashFiles {
+		if err := hashFile(h, path, c.resolvePath(path)); err != nil {
+			return "", err
+		}
+	}
+
+	fmt.Fprintf(h, "values %q\n", c.cache.key.Values)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resolvePath returns path relative to the command's Dir
+func (c *Command) resolvePath(path string) string {
+	if filepath.IsAbs(path) || c.dir == "" {
+		return path
+	}
+
+	return filepath.Join(c.dir, path)
+}
+
+func hashFile(h hash.Hash, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(h, "hash %q\n", name)
+	_, err = io.Copy(h, f)
+
+	return err
+}
+
+// path returns the file of the entry stored with key
+func (s *CacheStore) path(key string) string {
+	return filepath.Join(s.Dir, key+".json")
+}
+
+// load returns the entry stored with key if it's not expired, and marks it as recently used
+func (s *CacheStore) load(key string) (cacheEntry, bool) {
+	var entry cacheEntry
+
+	path := s.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &entry) != nil {
+		return entry, false
+	}
+
+	if s.TTL > 0 && time.Since(entry.Created) > s.TTL {
+		os.Remove(path)
+		return entry, false
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+
+	return entry, true
+}
+
+// save stores entry with key then evicts entries over the TTL or MaxSize
+func (s *CacheStore) save(key string, entry cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return
+	}
+
+	// write to a temporary file first so concurrent readers never see partial entries
+	tmp, err := os.CreateTemp(s.Dir, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(tmp.Name(), s.path(key)) != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	s.evict()
+}
+
+// evict removes the expired entries then the least recently used ones until the store fits
+// MaxSize
+func (s *CacheStore) evict() {
+	files, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return
+	}
+
+	type stored struct {
+		path string
+		size int64
+		used time.Time
+	}
+
+	var entries []stored
+	var total int64
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(s.Dir, file.Name())
+		if s.TTL > 0 {
+			if created, err := entryCreated(path); err != nil || time.Since(created) > s.TTL {
+				os.Remove(path)
+				continue
+			}
+		}
+
+		entries = append(entries, stored{path: path, size: info.Size(), used: info.ModTime()})
+		total += info.Size()
+	}
+
+	if s.MaxSize <= 0 || total <= s.MaxSize {
+		return
+	}
+
+	slices.SortFunc(entries, func(a, b stored) int { return a.used.Compare(b.used) })
+	for _, entry := range entries {
+		if total <= s.MaxSize {
+			break
+		}
+		if os.Remove(entry.path) == nil {
+			total -= entry.size
+		}
+	}
+}
+
+// entryCreated returns when the entry stored in path was created, reading the start of the file only
+func entryCreated(path string) (time.Time, error) {
+	var created time.Time
+
+	f, err := os.Open(path)
+	if err != nil {
+		return created, err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	if _, err := decoder.Token(); err != nil {
+		return created, err
+	}
+	if field, err := decoder.Token(); err != nil || field != "created" {
+		return created, fmt.Errorf("cache entry %s doesn't start with its creation time", path)
+	}
+	err = decoder.Decode(&created)
+
+	return created, err
+}
@@ -0,0 +1,230 @@
+package types
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Cache(t *testing.T) {
+	// counter returns a command function counting its runs
+	counter := func(runs *int) *Command {
+		return CmdFn(func(stdin string) (string, string, error) {
+			*runs++
+			return "out:" + stdin, "err", nil
+		})
+	}
+
+	t.Run("serves results of the same key", func(t *testing.T) {
+		store := &CacheStore{Dir: t.TempDir()}
+		runs := 0
+
+		require.Equal(t, "out:a", counter(&runs).Input("a").Cache(store, CacheKey{}).Stdout())
+		cached := counter(&runs).Input("a").Cache(store, CacheKey{})
+		require.Equal(t, "out:a", cached.Stdout())
+		require.Equal(t, "err", cached.Stderr())
+		require.NoError(t, cached.Error())
+		require.Equal(t, 1, runs)
+
+		require.Equal(t, "out:b", counter(&runs).Input("b").Cache(store, CacheKey{}).Stdout())
+		require.Equal(t, 2, runs)
+	})
+
+	t.Run("stores exit codes of successful runs only", func(t *testing.T) {
+		store := &CacheStore{Dir: t.TempDir()}
+
+		cmd := Script("sh", "echo run >> "+filepath.Join(store.Dir, "runs.log")+"; exit 1").AllowExitCodes(1)
+		require.Equal(t, 1, cmd.Cache(store, CacheKey{}).ExitCode())
+		cmd = Script("sh", "echo run >> "+filepath.Join(store.Dir, "runs.log")+"; exit 1").AllowExitCodes(1)
+		require.Equal(t, 1, cmd.Cache(store, CacheKey{}).ExitCode())
+		require.Equal(t, "run\n", Cmd("cat", filepath.Join(store.Dir, "runs.log")).Stdout())
+
+		runs := 0
+		failing := func() *Command {
+			return CmdFn(func(string) (string, string, error) {
+				runs++
+				return "", "", os.ErrNotExist
+			}).Cache(store, CacheKey{})
+		}
+		require.Error(t, failing().Error())
+		require.Error(t, failing().Error())
+		require.Equal(t, 2, runs)
+	})
+
+	t.Run("key includes pipeline, env and files", func(t *testing.T) {
+		store := &CacheStore{Dir: t.TempDir()}
+		dir := t.TempDir()
+		file := filepath.Join(dir, "input.txt")
+		require.NoError(t, os.WriteFile(file, []byte("v1"), 0o644))
+
+		cmd := func() *Command {
+			return Cmd("sh", "-c", "echo $VERSION $INHERITED").Env("VERSION", "1").Dir(dir).
+				Cache(store, CacheKey{Env: []string{"INHERITED"}, HashFiles: []string{"input.txt"}, Files: []string{file}})
+		}
+
+		t.Setenv("INHERITED", "a")
+		require.Equal(t, "1 a\n", cmd().Stdout())
+
+		t.Setenv("INHERITED", "b")
+		require.Equal(t, "1 b\n", cmd().Stdout())
+		require.Equal(t, "2 b\n", cmd().Env("VERSION", "2").Stdout())
+
+		t.Setenv("INHERITED", "a")
+		require.Equal(t, "1 a\n", cmd().Stdout())
+
+		// the cached result is served until the file changes
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "marker"), nil, 0o644))
+		marked := func() *Command {
+			return Cmd("sh", "-c", "ls marker").Dir(dir).Cache(store, CacheKey{HashFiles: []string{"input.txt"}})
+		}
+		require.Equal(t, "marker\n", marked().Stdout())
+		require.NoError(t, os.Remove(filepath.Join(dir, "marker")))
+		require.Equal(t, "marker\n", marked().Stdout())
+		require.NoError(t, os.WriteFile(file, []byte("v2"), 0o644))
+		require.Error(t, marked().Error())
+
+		require.Equal(t, "FROM PIPE\n", Cmd("echo", "from pipe").Pipe("tr", "a-z", "A-Z").Cache(store, CacheKey{}).Stdout())
+		require.Equal(t, "FROM PIPE\n", Cmd("echo", "from pipe").Pipe("tr", "a-z", "A-Z").Cache(store, CacheKey{}).Stdout())
+		require.Equal(t, "OTHER\n", Cmd("echo", "other").Pipe("tr", "a-z", "A-Z").Cache(store, CacheKey{}).Stdout())
+	})
+
+	t.Run("key includes env files, inherited and expanded variables", func(t *testing.T) {
+		store := &CacheStore{Dir: t.TempDir()}
+		dir := t.TempDir()
+		envFile := filepath.Join(dir, ".env")
+
+		fromFile := func() *Command {
+			return Cmd("sh", "-c", "echo $NAME").EnvFile(envFile).Cache(store, CacheKey{})
+		}
+		require.NoError(t, os.WriteFile(envFile, []byte("NAME=one\n"), 0o600))
+		require.Equal(t, "one\n", fromFile().Stdout())
+		require.NoError(t, os.WriteFile(envFile, []byte("NAME=two\n"), 0o600))
+		require.Equal(t, "two\n", fromFile().Stdout())
+
+		inherited := func() *Command {
+			return Cmd("sh", "-c", "echo $CACHE_INHERITED").ClearEnv().InheritEnv("CACHE_INHERITED").Cache(store, CacheKey{})
+		}
+		t.Setenv("CACHE_INHERITED", "a")
+		require.Equal(t, "a\n", inherited().Stdout())
+		t.Setenv("CACHE_INHERITED", "b")
+		require.Equal(t, "b\n", inherited().Stdout())
+
+		expanded := func() *Command {
+			return Cmd("echo", "${CACHE_EXPANDED}").ExpandEnv().Cache(store, CacheKey{})
+		}
+		t.Setenv("CACHE_EXPANDED", "a")
+		require.Equal(t, "a\n", expanded().Stdout())
+		t.Setenv("CACHE_EXPANDED", "b")
+		require.Equal(t, "b\n", expanded().Stdout())
+	})
+
+	t.Run("cached commands can be piped", func(t *testing.T) {
+		store := &CacheStore{Dir: t.TempDir()}
+		runs := 0
+
+		require.Equal(t, "OUT:A", counter(&runs).Input("a").Cache(store, CacheKey{}).Pipe("tr", "a-z", "A-Z").Stdout())
+		require.Equal(t, "OUT:A", counter(&runs).Input("a").Cache(store, CacheKey{}).Pipe("tr", "a-z", "A-Z").Stdout())
+		require.Equal(t, 1, runs)
+	})
+
+	t.Run("expires entries after the TTL", func(t *testing.T) {
+		store := &CacheStore{Dir: t.TempDir(), TTL: 50 * time.Millisecond}
+		runs := 0
+
+		counter(&runs).Cache(store, CacheKey{}).Run()
+		counter(&runs).Cache(store, CacheKey{}).Run()
+		require.Equal(t, 1, runs)
+
+		time.Sleep(60 * time.Millisecond)
+		counter(&runs).Cache(store, CacheKey{}).Run()
+		require.Equal(t, 2, runs)
+	})
+
+	t.Run("evicts entries by creation time", func(t *testing.T) {
+		store := &CacheStore{Dir: t.TempDir(), TTL: time.Hour}
+		runs := 0
+
+		counter(&runs).Input("a").Cache(store, CacheKey{}).Run()
+		entries, err := filepath.Glob(filepath.Join(store.Dir, "*.json"))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		// stored two hours ago, used just now
+		var entry cacheEntry
+		data, err := os.ReadFile(entries[0])
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &entry))
+		entry.Created = time.Now().Add(-2 * time.Hour)
+		data, err = json.Marshal(entry)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(entries[0], data, 0o644))
+
+		counter(&runs).Input("b").Cache(store, CacheKey{}).Run()
+		_, err = os.Stat(entries[0])
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("key includes functions and values", func(t *testing.T) {
+		store := &CacheStore{Dir: t.TempDir()}
+		upper := func(stdin string) (string, string, error) { return strings.ToUpper(stdin), "", nil }
+		lower := func(stdin string) (string, string, error) { return strings.ToLower(stdin), "", nil }
+
+		require.Equal(t, "ABC", CmdFn(upper).Input("aBc").Cache(store, CacheKey{}).Stdout())
+		require.Equal(t, "abc", CmdFn(lower).Input("aBc").Cache(store, CacheKey{}).Stdout())
+
+		prefix := func(p string) *Command {
+			return Cmd("printf", "a\nb\n").ForEachLine(func(line string) *Command {
+				return Cmd("echo", p+line)
+			}, XargsOptions{Ordered: true}).Cache(store, CacheKey{Values: []string{p}})
+		}
+		require.Equal(t, "1a\n1b\n", prefix("1").Stdout())
+		require.Equal(t, "2a\n2b\n", prefix("2").Stdout())
+
+		echo := func(lines []string) *Command { return Cmd("echo", lines...) }
+		count := func(lines []string) *Command { return Cmd("echo", strconv.Itoa(len(lines))) }
+		require.Equal(t, "a b\n", Cmd("printf", "a\nb\n").Xargs(echo, XargsOptions{Batch: 2}).Cache(store, CacheKey{}).Stdout())
+		require.Equal(t, "2\n", Cmd("printf", "a\nb\n").Xargs(count, XargsOptions{Batch: 2}).Cache(store, CacheKey{}).Stdout())
+	})
+
+	t.Run("evicts least recently used entries over the max size", func(t *testing.T) {
+		store := &CacheStore{Dir: t.TempDir()}
+		runs := 0
+
+		counter(&runs).Input("a").Cache(store, CacheKey{}).Run()
+		entries, err := filepath.Glob(filepath.Join(store.Dir, "*.json"))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		info, err := os.Stat(entries[0])
+		require.NoError(t, err)
+
+		// room for two entries, their sizes differ slightly by the creation time
+		store.MaxSize = 2*info.Size() + info.Size()/2
+		past := time.Now().Add(-time.Hour)
+		require.NoError(t, os.Chtimes(entries[0], past, past))
+		counter(&runs).Input("b").Cache(store, CacheKey{}).Run()
+
+		// using a makes b the least recently used
+		counter(&runs).Input("a").Cache(store, CacheKey{}).Run()
+		require.Equal(t, 2, runs)
+
+		counter(&runs).Input("c").Cache(store, CacheKey{}).Run()
+		counter(&runs).Input("a").Cache(store, CacheKey{}).Run()
+		counter(&runs).Input("b").Cache(store, CacheKey{}).Run()
+		require.Equal(t, 4, runs)
+	})
+
+	t.Run("runs without caching when the key can't be computed", func(t *testing.T) {
+		store := &CacheStore{Dir: t.TempDir()}
+		runs := 0
+
+		counter(&runs).Cache(store, CacheKey{Files: []string{"missing"}}).Run()
+		counter(&runs).Cache(store, CacheKey{Files: []string{"missing"}}).Run()
+		require.Equal(t, 2, runs)
+	})
+}
@@ -0,0 +1,249 @@
+package types
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// InheritEnv limits the inherited environment to the variables named keys, like ClearEnv
+// except for these variables. It can be combined with InheritEnvPrefix, variables set
+// with Env or EnvFile are always passed.
+//
+// Example:
+//
+//	output := types.Cmd("make").InheritEnv("PATH", "HOME").Env("CC", "clang").Stdout()
+func (c *Command) InheritEnv(keys ...string) *Command {
+	c.inheritEnv = append(c.inheritEnv, keys...)
+	return c
+}
+
+// InheritEnvPrefix limits the inherited environment to the variables starting with prefix,
+// see InheritEnv.
+//
+// Example:
+//
+//	err := types.Cmd("aws", "s3", "ls").InheritEnv("PATH").InheritEnvPrefix("AWS_").Error()
+func (c *Command) InheritEnvPrefix(prefix string) *Command {
+	c.inheritEnvPrefix = append(c.inheritEnvPrefix, prefix)
+	return c
+}
+
+// EnvFile adds the variables of a dotenv file to the environment when the command is
+// executed. Variables set with Env take precedence over env files, and later files over
+// earlier ones. A relative path is relative to Dir.
+//
+// Each line is KEY=value, optionally prefixed with export. Lines starting with # are
+// comments. Values may be single quoted to be used literally, or double quoted to support
+// escapes (\n, \t, \", \\) and span several lines. Unquoted and double quoted values expand
+// ${VAR} with the variables defined before them, unquoted values end at " #".
+//
+// Example:
+//
+//	err := types.Cmd("./server").EnvFile(".env").EnvFile(".env.local").Error()
+func (c *Command) EnvFile(path string) *Command {
+	c.envFiles = append(c.envFiles, path)
+	return c
+}
+
+// ExpandEnv replaces ${VAR} in arguments with the value of VAR in the command's
+// effective environment, or nothing if it's not set. String still shows the arguments
+// before expansion.
+//
+// Example:
+//
+//	output := types.Cmd("echo", "deploying ${APP} to ${ENV}").EnvFile(".env").ExpandEnv().Stdout()
+func (c *Command) ExpandEnv() *Command {
+	c.expandEnv = true
+	return c
+}
+
+// EffectiveEnv returns the environment the command runs with as sorted KEY=value entries,
+// after applying ClearEnv, InheritEnv, InheritEnvPrefix, EnvFile and Env. The returned
+// error is about reading env files.
+//
+// Example:
+//
+//	env, err := types.Cmd("./server").InheritEnv("PATH").EnvFile(".env").EffectiveEnv()
+//	fmt.Println(strings.Join(env, "\n"))
+func (c *Command) EffectiveEnv() ([]string, error) {
+	env, err := c.environ()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]string, 0, len(env))
+	for _, key := range slices.Sorted(maps.Keys(env)) {
+		entries = append(entries, key+"="+env[key])
+	}
+
+	return entries, nil
+}
+
+// customEnv reports whether the command environment differs from the current process
+func (c *Command) customEnv() bool {
+	return c.clearEnv || c.env != nil || c.inheritEnv != nil || c.inheritEnvPrefix != nil || c.envFiles != nil
+}
+
+// environ returns the effective environment of the command, each key appears only once
+// with the value of the highest precedence: Env, then env files, then inherited variables
+func (c *Command) environ() (map[string]string, error) {
+	env := map[string]string{}
+
+	allowlisted := c.inheritEnv != nil || c.inheritEnvPrefix != nil
+	if !c.clearEnv || allowlisted {
+		for _, entry := range os.Environ() {
+			key, value, _ := strings.Cut(entry, "=")
+			if allowlisted && !c.inherits(key) {
+				continue
+			}
+			env[key] = value
+		}
+	}
+
+	for _, path := range c.envFiles {
+		data, err := os.ReadFile(c.resolvePath(path))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := parseEnvFile(string(data), env); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	maps.Copy(env, c.env)
+
+	return env, nil
+}
+
+// expandArgs returns the arguments passed to the process, with variables of env and globs
+// expanded when requested
+func (c *Command) expandArgs(env map[string]string) ([]string, error) {
+	args := c.args
+	if c.expandEnv {
+		args = make([]string, len(c.args))
+		for i, arg := range c.args {
+			args[i] = expandVars(arg, env)
+		}
+	}
+
+	if c.expandGlobs {
+		return c.globArgs(args)
+	}
+
+	return args, nil
+}
+
+// inherits reports whether the allowlists include the variable key
+func (c *Command) inherits(key string) bool {
+	return slices.Contains(c.inheritEnv, key) ||
+		slices.ContainsFunc(c.inheritEnvPrefix, func(prefix string) bool { return strings.HasPrefix(key, prefix) })
+}
+
+// envVarPattern matches ${VAR} references
+var envVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandVars replaces ${VAR} in s with values from env
+func expandVars(s string, env map[string]string) string {
+	return envVarPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		return env[ref[2:len(ref)-1]]
+	})
+}
+
+// envKeyPattern matches valid variable names
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// parseEnvFile parses dotenv content into env, values can reference variables already in env
+func parseEnvFile(content string, env map[string]string) error {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	line := 0
+
+	for content != "" {
+		var current string
+		current, content, _ = strings.Cut(content, "\n")
+		line++
+
+		current = strings.TrimSpace(current)
+		if current == "" || strings.HasPrefix(current, "#") {
+			continue
+		}
+
+		current = strings.TrimPrefix(current, "export ")
+		key, value, ok := strings.Cut(current, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !envKeyPattern.MatchString(key) {
+			return fmt.Errorf("line %d: invalid variable definition %q", line, current)
+		}
+		value = strings.TrimLeft(value, " \t")
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return fmt.Errorf("line %d: unterminated single quoted value of %s", line, key)
+			}
+			env[key] = value[1 : end+1]
+
+		case strings.HasPrefix(value, `"`):
+			start := line
+			value = value[1:]
+			var unquoted strings.Builder
+			for closed := false; !closed; {
+				for i := 0; i < len(value); i++ {
+					switch {
+					case value[i] == '\\' && i+1 < len(value):
+						i++
+						switch value[i] {
+						case 'n':
+							unquoted.WriteByte('\n')
+						case 't':
+							unquoted.WriteByte('\t')
+						case '"', '\\', '$':
+							unquoted.WriteByte(value[i])
+						default:
+							unquoted.WriteByte('\\')
+							unquoted.WriteByte(value[i])
+						}
+					case value[i] == '"':
+						closed = true
+					case value[i] == '$':
+						if ref := envVarPattern.FindStringIndex(value[i:]); ref != nil && ref[0] == 0 {
+							unquoted.WriteString(env[value[i+2:i+ref[1]-1]])
+							i += ref[1] - 1
+						} else {
+							unquoted.WriteByte('$')
+						}
+					default:
+						unquoted.WriteByte(value[i])
+					}
+					if closed {
+						break
+					}
+				}
+
+				if !closed {
+					if content == "" {
+						return fmt.Errorf("line %d: unterminated double quoted value of %s", start, key)
+					}
+					// the value continues on the next line
+					unquoted.WriteByte('\n')
+					value, content, _ = strings.Cut(content, "\n")
+					line++
+				}
+			}
+			env[key] = unquoted.String()
+
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = value[:i]
+			}
+			env[key] = expandVars(strings.TrimSpace(value), env)
+		}
+	}
+
+	return nil
+}
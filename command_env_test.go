@@ -0,0 +1,100 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_InheritEnv(t *testing.T) {
+	t.Setenv("TYPES_KEEP", "keep")
+	t.Setenv("TYPES_DROP", "drop")
+	t.Setenv("TYPES_AWS_REGION", "eu-west-1")
+	t.Setenv("TYPES_AWS_PROFILE", "dev")
+
+	cmd := Cmd("env").InheritEnv("TYPES_KEEP", "PATH").InheritEnvPrefix("TYPES_AWS_").Env("SET", "value")
+	env, err := cmd.EffectiveEnv()
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"PATH=" + os.Getenv("PATH"),
+		"SET=value",
+		"TYPES_AWS_PROFILE=dev",
+		"TYPES_AWS_REGION=eu-west-1",
+		"TYPES_KEEP=keep",
+	}, env)
+	require.Equal(t, "PATH="+os.Getenv("PATH")+"\nSET=value\nTYPES_AWS_PROFILE=dev\nTYPES_AWS_REGION=eu-west-1\nTYPES_KEEP=keep\n", Cmd("env").InheritEnv("TYPES_KEEP", "PATH").InheritEnvPrefix("TYPES_AWS_").Env("SET", "value").Pipe("sort").Stdout())
+
+	env, err = Cmd("env").ClearEnv().InheritEnv("TYPES_KEEP").EffectiveEnv()
+	require.NoError(t, err)
+	require.Equal(t, []string{"TYPES_KEEP=keep"}, env)
+}
+
+func TestCommand_EnvFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte(`# database
+export DB_HOST=localhost
+DB_PORT = 5432 # default port
+DB_URL=postgres://${DB_HOST}:${DB_PORT}/app
+SINGLE='literal ${DB_HOST} # not a comment'
+DOUBLE="tab\tquote\" dollar \${DB_HOST} ${DB_HOST}"
+MULTI="first
+second"
+OVERRIDDEN=file
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env.local"), []byte("DB_HOST=db.local\r\n"), 0o644))
+
+	cmd := Cmd("sh", "-c", `echo "$DB_URL|$SINGLE|$DOUBLE|$MULTI|$OVERRIDDEN|$DB_HOST"`).
+		Dir(dir).
+		EnvFile(".env").
+		EnvFile(".env.local").
+		Env("OVERRIDDEN", "env")
+
+	require.Equal(t, "postgres://localhost:5432/app|literal ${DB_HOST} # not a comment|tab\tquote\" dollar ${DB_HOST} localhost|first\nsecond|env|db.local\n", cmd.Stdout())
+
+	t.Run("errors", func(t *testing.T) {
+		err := Cmd("true").EnvFile(filepath.Join(dir, "missing")).Error()
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		tcs := []struct {
+			content string
+			err     string
+		}{
+			{"VALID=1\nnot a definition\n", `bad: line 2: invalid variable definition "not a definition"`},
+			{"A='open\n", "bad: line 1: unterminated single quoted value of A"},
+			{"A=1\nB=\"open\nstill open\n", "bad: line 2: unterminated double quoted value of B"},
+		}
+		for _, tc := range tcs {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "bad"), []byte(tc.content), 0o644))
+			_, err := Cmd("true").Dir(dir).EnvFile("bad").EffectiveEnv()
+			require.EqualError(t, err, tc.err)
+		}
+	})
+}
+
+func TestCommand_ExpandEnv(t *testing.T) {
+	t.Setenv("TYPES_TARGET", "world")
+
+	cmd := Cmd("echo", "hello ${TYPES_TARGET} ${LOCAL} ${UNSET}.").Env("LOCAL", "local").ExpandEnv()
+	require.Equal(t, "hello world local .\n", cmd.Stdout())
+	require.Equal(t, "echo hello ${TYPES_TARGET} ${LOCAL} ${UNSET}.", cmd.String())
+
+	require.Equal(t, "${TYPES_TARGET}\n", Cmd("echo", "${TYPES_TARGET}").Stdout())
+}
+
+func TestCommand_EnvDuplicates(t *testing.T) {
+	t.Setenv("TYPES_DUPLICATE", "inherited")
+
+	command, err := Cmd("env").Env("TYPES_DUPLICATE", "set").buildCommand()
+	require.NoError(t, err)
+
+	var found []string
+	for _, entry := range command.Env {
+		if strings.HasPrefix(entry, "TYPES_DUPLICATE=") {
+			found = append(found, entry)
+		}
+	}
+	require.Equal(t, []string{"TYPES_DUPLICATE=set"}, found)
+}
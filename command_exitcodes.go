@@ -0,0 +1,78 @@
+package types
+
+// ExitCodeProfile groups the exit codes of a tool that are not failures and the meaning of its exit codes
+type ExitCodeProfile struct {
+	Allowed  []int
+	Meanings map[int]string
+}
+
+// GrepExitCodes returns a profile allowing grep's "no lines selected" exit code
+func GrepExitCodes() ExitCodeProfile {
+	return ExitCodeProfile{
+		Allowed: []int{1},
+		Meanings: map[int]string{
+			1: "no lines selected",
+			2: "an error occurred",
+		},
+	}
+}
+
+// DiffExitCodes returns a profile allowing diff and cmp "differences found" exit code
+func DiffExitCodes() ExitCodeProfile {
+	return ExitCodeProfile{
+		Allowed: []int{1},
+		Meanings: map[int]string{
+			1: "differences found",
+			2: "trouble",
+		},
+	}
+}
+
+// RsyncExitCodes returns a profile allowing rsync's "vanished source files" exit code
+func RsyncExitCodes() ExitCodeProfile {
+	return ExitCodeProfile{
+		Allowed: []int{24},
+		Meanings: map[int]string{
+			1:  "syntax or usage error",
+			2:  "protocol incompatibility",
+			3:  "errors selecting input/output files, dirs",
+			4:  "requested action not supported",
+			5:  "error starting client-server protocol",
+			6:  "daemon unable to append to log-file",
+			10: "error in socket I/O",
+			11: "error in file I/O",
+			12: "error in rsync protocol data stream",
+			13: "errors with program diagnostics",
+			14: "error in IPC code",
+			20: "received SIGUSR1 or SIGINT",
+			21: "some error returned by waitpid()",
+			22: "error allocating core memory buffers",
+			23: "partial transfer due to error",
+			24: "partial transfer due to vanished source files",
+			25: "the --max-delete limit stopped deletions",
+			30: "timeout in data send/receive",
+			35: "timeout waiting for daemon connection",
+		},
+	}
+}
+
+// CurlExitCodes returns a profile describing curl's common failures, no failure is allowed
+func CurlExitCodes() ExitCodeProfile {
+	return ExitCodeProfile{
+		Meanings: map[int]string{
+			1:  "unsupported protocol",
+			3:  "URL malformed",
+			5:  "couldn't resolve proxy",
+			6:  "couldn't resolve host",
+			7:  "failed to connect to host",
+			22: "HTTP error returned (--fail)",
+			23: "write error",
+			28: "operation timeout",
+			35: "SSL connect error",
+			47: "too many redirects",
+			52: "server returned nothing",
+			56: "failure receiving network data",
+			60: "peer certificate cannot be authenticated",
+		},
+	}
+}
@@ -0,0 +1,148 @@
+package types
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_AllowExitCodes(t *testing.T) {
+	tests := []struct {
+		name         string
+		cmd          *Command
+		expectedCode int
+		expectErr    bool
+	}{
+		{
+			name:         "allowed code is not an error",
+			cmd:          Cmd("sh", "-c", "exit 1").AllowExitCodes(0, 1),
+			expectedCode: 1,
+		},
+		{
+			name:         "other codes are still errors",
+			cmd:          Cmd("sh", "-c", "exit 2").AllowExitCodes(0, 1),
+			expectedCode: 2,
+			expectErr:    true,
+		},
+		{
+			name:         "multiple calls accumulate",
+			cmd:          Cmd("sh", "-c", "exit 24").AllowExitCodes(1).AllowExitCodes(24),
+			expectedCode: 24,
+		},
+		{
+			name:      "errors without exit code are not allowed",
+			cmd:       Cmd("nonexistent-command-xyz").AllowExitCodes(0, 1, 127),
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedCode, tt.cmd.ExitCode())
+			if tt.expectErr {
+				require.Error(t, tt.cmd.Error())
+			} else {
+				require.NoError(t, tt.cmd.Error())
+			}
+		})
+	}
+
+	t.Run("allowed code in a pipeline", func(t *testing.T) {
+		cmd := Cmd("echo", "hello").Pipe("grep", "missing").AllowExitCodes(1).Pipe("wc", "-l")
+		require.Equal(t, "0", cmd.StdoutTrimmed())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("allowed code upstream of a streaming pipe", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "echo out; exit 1").AllowExitCodes(1).Pipe("cat")
+		require.Equal(t, "out\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("allowed code is not retried", func(t *testing.T) {
+		counter := filepath.Join(t.TempDir(), "counter")
+		cmd := Cmd("sh", "-c", "echo x >> "+counter+"; exit 1").AllowExitCodes(1).Retry(3)
+		require.NoError(t, cmd.Error())
+
+		content, err := os.ReadFile(counter)
+		require.NoError(t, err)
+		require.Equal(t, "x\n", string(content))
+	})
+}
+
+func TestCommand_ExitCodeMeanings(t *testing.T) {
+	t.Run("adds meaning to the error", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "exit 3").ExitCodeMeanings(map[int]string{3: "config not found"})
+
+		err := cmd.Error()
+		require.EqualError(t, err, "exit status 3: config not found")
+
+		var exitErr *exec.ExitError
+		require.True(t, errors.As(err, &exitErr))
+		require.Equal(t, 3, exitErr.ExitCode())
+	})
+
+	t.Run("codes without meaning are unchanged", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "exit 4").ExitCodeMeanings(map[int]string{3: "config not found"})
+		require.EqualError(t, cmd.Error(), "exit status 4")
+	})
+}
+
+func TestExitCodeProfiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		cmd       *Command
+		expectErr string
+	}{
+		{
+			name: "grep no match",
+			cmd:  Cmd("grep", "missing").Input("hello\n").ExitCodes(GrepExitCodes()),
+		},
+		{
+			name:      "grep error",
+			cmd:       Cmd("grep", "x", "/nonexistent/file").ExitCodes(GrepExitCodes()),
+			expectErr: "exit status 2: an error occurred",
+		},
+		{
+			name: "diff differences",
+			cmd:  Cmd("diff", "-", "/dev/null").Input("line\n").ExitCodes(DiffExitCodes()),
+		},
+		{
+			name: "rsync vanished files",
+			cmd:  Cmd("sh", "-c", "exit 24").ExitCodes(RsyncExitCodes()),
+		},
+		{
+			name:      "rsync partial transfer",
+			cmd:       Cmd("sh", "-c", "exit 23").ExitCodes(RsyncExitCodes()),
+			expectErr: "exit status 23: partial transfer due to error",
+		},
+		{
+			name:      "curl timeout",
+			cmd:       Cmd("sh", "-c", "exit 28").ExitCodes(CurlExitCodes()),
+			expectErr: "exit status 28: operation timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.expectErr == "" {
+				require.NoError(t, tt.cmd.Error())
+			} else {
+				require.EqualError(t, tt.cmd.Error(), tt.expectErr)
+			}
+		})
+	}
+
+	t.Run("profiles can't be changed by callers", func(t *testing.T) {
+		profile := GrepExitCodes()
+		profile.Allowed[0] = 2
+		profile.Meanings[1] = "changed"
+
+		require.Equal(t, []int{1}, GrepExitCodes().Allowed)
+		require.Equal(t, "no lines selected", GrepExitCodes().Meanings[1])
+	})
+}
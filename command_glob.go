@@ -0,0 +1,295 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// GlobMode controls what ExpandGlobsWith does with patterns matching no file
+type GlobMode int
+
+const (
+	// GlobKeep passes patterns matching no file as is, like a shell by default
+	GlobKeep GlobMode = iota
+	// GlobNull removes patterns matching no file, like the nullglob shell option
+	GlobNull
+	// GlobFail fails the command when a pattern matches no file, like the failglob shell option
+	GlobFail
+)
+
+// ExpandGlobs expands patterns in arguments to the matching paths relative to Dir when the
+// command is executed, like a shell without running one. It supports *, ?, [...], **
+// matching any number of directories and {a,b} alternatives. Matches of each pattern are
+// sorted and names starting with a dot only match patterns starting with a dot. A
+// backslash escapes a pattern character and is removed from the argument. Patterns
+// matching no file are passed as is, see ExpandGlobsWith. String still shows the
+// arguments before expansion.
+//
+// Example:
+//
+//	err := types.Cmd("rm", "*.log", "tmp/**/*.{o,a}").Dir(project).ExpandGlobs().Error()
+func (c *Command) ExpandGlobs() *Command {
+	return c.ExpandGlobsWith(GlobKeep)
+}
+
+// ExpandGlobsWith is like ExpandGlobs with mode controlling patterns matching no file
+//
+// Example:
+//
+//	output, err := types.Cmd("cat", "reports/*.csv").ExpandGlobsWith(types.GlobFail).StdoutErr()
+//	// err: no matches found: reports/*.csv
+func (c *Command) ExpandGlobsWith(mode GlobMode) *Command {
+	c.expandGlobs = true
+	c.globMode = mode
+	return c
+}
+
+// globArgs expands braces and glob patterns of args
+func (c *Command) globArgs(args []string) ([]string, error) {
+	expanded := []string{}
+	for _, arg := range args {
+		for _, word := range expandBraces(arg) {
+			if !hasGlobMeta(word) {
+				expanded = append(expanded, unescapeGlob(word))
+				continue
+			}
+
+			matches, err := c.glob(word)
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case len(matches) > 0:
+				expanded = append(expanded, matches...)
+			case c.globMode == GlobKeep:
+				expanded = append(expanded, unescapeGlob(word))
+			case c.globMode == GlobFail:
+				return nil, fmt.Errorf("no matches found: %s", word)
+			}
+		}
+	}
+
+	return expanded, nil
+}
+
+// glob returns the sorted paths matching pattern, relative to Dir unless pattern is absolute
+func (c *Command) glob(pattern string) ([]string, error) {
+	pattern = filepath.ToSlash(pattern)
+
+	prefix := ""
+	if filepath.IsAbs(filepath.FromSlash(pattern)) {
+		prefix = filepath.ToSlash(filepath.VolumeName(filepath.FromSlash(pattern))) + "/"
+		pattern = strings.TrimPrefix(pattern, prefix)
+	}
+
+	var matches []string
+	var walk func(prefix string, segments []string) error
+	walk = func(prefix string, segments []string) error {
+		if len(segments) == 0 {
+			matches = append(matches, prefix)
+			return nil
+		}
+
+		segment, rest := segments[0], segments[1:]
+		switch {
+		case segment == "" && len(rest) == 0:
+			// a trailing slash only matches directories
+			if info, err := os.Stat(c.globPath(prefix)); err == nil && info.IsDir() {
+				matches = append(matches, prefix+"/")
+			}
+			return nil
+
+		case segment == "":
+			return walk(prefix, rest)
+
+		case !hasGlobMeta(segment):
+			name := globJoin(prefix, unescapeGlob(segment))
+			if len(rest) == 0 {
+				if _, err := os.Lstat(c.globPath(name)); err == nil {
+					matches = append(matches, name)
+				}
+				return nil
+			}
+			if info, err := os.Stat(c.globPath(name)); err == nil && info.IsDir() {
+				return walk(name, rest)
+			}
+			return nil
+		}
+
+		entries, err := os.ReadDir(c.globPath(prefix))
+		if err != nil {
+			return nil
+		}
+
+		if segment == "**" {
+			// ** matches the directory itself, then recurses without following symbolic links
+			if len(rest) > 0 {
+				if err := walk(prefix, rest); err != nil {
+					return err
+				}
+			}
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), ".") {
+					continue
+				}
+				name := globJoin(prefix, entry.Name())
+				if len(rest) == 0 {
+					matches = append(matches, name)
+				}
+				if entry.IsDir() {
+					if err := walk(name, segments); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), ".") && !strings.HasPrefix(segment, ".") {
+				continue
+			}
+
+			ok, err := path.Match(segment, entry.Name())
+			if errors.Is(err, path.ErrBadPattern) {
+				// a malformed pattern like a[ matches nothing, like in a shell
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("glob %q: %w", pattern, err)
+			}
+			if !ok {
+				continue
+			}
+
+			name := globJoin(prefix, entry.Name())
+			if len(rest) == 0 {
+				matches = append(matches, name)
+			} else if info, err := os.Stat(c.globPath(name)); err == nil && info.IsDir() {
+				if err := walk(name, rest); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(prefix, strings.Split(pattern, "/")); err != nil {
+		return nil, err
+	}
+
+	slices.Sort(matches)
+	matches = slices.Compact(matches)
+	for i, match := range matches {
+		matches[i] = filepath.FromSlash(match)
+	}
+
+	return matches, nil
+}
+
+// globPath returns the path of a slash separated match on disk
+func (c *Command) globPath(name string) string {
+	if name == "" {
+		name = "."
+	}
+
+	return c.resolvePath(filepath.FromSlash(name))
+}
+
+// globJoin joins a match prefix and an entry name
+func globJoin(prefix, name string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix + name
+	}
+
+	return prefix + "/" + name
+}
+
+// hasGlobMeta reports whether s has an unescaped *, ? or [
+func hasGlobMeta(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+
+	return false
+}
+
+// unescapeGlob removes the backslashes escaping pattern characters, other backslashes are kept
+func unescapeGlob(s string) string {
+	var unescaped strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`*?[]{},\\`, s[i+1]) >= 0 {
+			i++
+		}
+		unescaped.WriteByte(s[i])
+	}
+
+	return unescaped.String()
+}
+
+// expandBraces expands {a,b} alternatives of s in order, including nested ones.
+// Braces without a comma at their level or escaped with a backslash are kept.
+func expandBraces(s string) []string {
+	for start := 0; start < len(s); start++ {
+		if s[start] == '\\' {
+			start++
+			continue
+		}
+		if s[start] != '{' {
+			continue
+		}
+
+		end, commas := braceGroup(s, start)
+		if end < 0 || len(commas) == 0 {
+			continue
+		}
+
+		var expanded []string
+		bounds := slices.Concat([]int{start}, commas, []int{end})
+		for i := 0; i+1 < len(bounds); i++ {
+			alternative := s[:start] + s[bounds[i]+1:bounds[i+1]] + s[end+1:]
+			expanded = append(expanded, expandBraces(alternative)...)
+		}
+
+		return expanded
+	}
+
+	return []string{s}
+}
+
+// braceGroup returns the index of the brace closing the one at start and the commas at its
+// level, end is -1 when it's not closed
+func braceGroup(s string, start int) (end int, commas []int) {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, commas
+			}
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		}
+	}
+
+	return -1, nil
+}
@@ -0,0 +1,151 @@
+package types
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandGlobs(t *testing.T) {
+	ws, err := NewWorkspace()
+	require.NoError(t, err)
+	ws.CleanupWith(t)
+
+	require.NoError(t, ws.WriteFiles(map[string]string{
+		"a.log":             "",
+		"b.log":             "",
+		"c.txt":             "",
+		".hidden.log":       "",
+		"src/main.go":       "",
+		"src/pkg/util.go":   "",
+		"src/pkg/util.c":    "",
+		"src/.git/x.go":     "",
+		"[literal].txt":     "",
+		"star*.txt":         "",
+		"report-1.csv":      "",
+		"report-2.csv":      "",
+		"report-10.csv":     "",
+		"docs/readme.md":    "",
+		"docs/guide/one.md": "",
+	}))
+
+	tests := []struct {
+		name     string
+		args     []string
+		mode     GlobMode
+		expected []string
+		err      string
+	}{
+		{
+			name:     "star",
+			args:     []string{"*.log"},
+			expected: []string{"a.log", "b.log"},
+		},
+		{
+			name:     "hidden files need a leading dot",
+			args:     []string{".*.log"},
+			expected: []string{".hidden.log"},
+		},
+		{
+			name:     "question mark and class",
+			args:     []string{"report-?.csv", "[bc].*"},
+			expected: []string{"report-1.csv", "report-2.csv", "b.log", "c.txt"},
+		},
+		{
+			name:     "recursive",
+			args:     []string{"src/**/*.go"},
+			expected: []string{"src/main.go", "src/pkg/util.go"},
+		},
+		{
+			name:     "recursive at the end",
+			args:     []string{"docs/**"},
+			expected: []string{"docs/guide", "docs/guide/one.md", "docs/readme.md"},
+		},
+		{
+			name:     "braces",
+			args:     []string{"src/pkg/util.{go,c}", "x{1,2{a,b}}"},
+			expected: []string{"src/pkg/util.go", "src/pkg/util.c", "x1", "x2a", "x2b"},
+		},
+		{
+			name:     "braces with globs",
+			args:     []string{"{*.txt,src/*.go}"},
+			expected: []string{"[literal].txt", "c.txt", "star*.txt", "src/main.go"},
+		},
+		{
+			name:     "braces without alternatives are literal",
+			args:     []string{"{}", "{a}", `\{a,b}`},
+			expected: []string{"{}", "{a}", "{a,b}"},
+		},
+		{
+			name:     "escaped meta characters",
+			args:     []string{`\[literal\].txt`, `star\*.txt`, `\d+`},
+			expected: []string{"[literal].txt", "star*.txt", `\d+`},
+		},
+		{
+			name:     "trailing slash matches directories",
+			args:     []string{"*/"},
+			expected: []string{"docs/", "src/"},
+		},
+		{
+			name:     "no match is kept",
+			args:     []string{"*.md", "--flag"},
+			expected: []string{"*.md", "--flag"},
+		},
+		{
+			name:     "no match is removed with GlobNull",
+			args:     []string{"*.md", "--flag"},
+			mode:     GlobNull,
+			expected: []string{"--flag"},
+		},
+		{
+			name: "no match fails with GlobFail",
+			args: []string{"*.log", "*.md"},
+			mode: GlobFail,
+			err:  "no matches found: *.md",
+		},
+		{
+			name:     "bad patterns match nothing",
+			args:     []string{"[a", "a[", "*.{log,[}"},
+			expected: []string{"[a", "a[", "a.log", "b.log", "*.["},
+		},
+		{
+			name:     "no match is kept unescaped",
+			args:     []string{`no\*match*`},
+			expected: []string{"no*match*"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := ws.Cmd("echo", tt.args...).ExpandGlobsWith(tt.mode)
+			args, err := cmd.globArgs(cmd.args)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			for i, arg := range tt.expected {
+				tt.expected[i] = filepath.FromSlash(arg)
+			}
+			require.Equal(t, tt.expected, args)
+		})
+	}
+
+	t.Run("absolute patterns", func(t *testing.T) {
+		cmd := Cmd("echo", filepath.Join(ws.Dir(), "*.log")).ExpandGlobs()
+		args, err := cmd.globArgs(cmd.args)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(ws.Dir(), "a.log"), filepath.Join(ws.Dir(), "b.log")}, args)
+	})
+
+	t.Run("executes with expanded arguments", func(t *testing.T) {
+		cmd := ws.Cmd("echo", "*.log", "src/*").ExpandGlobs()
+		require.Equal(t, "a.log b.log src/main.go src/pkg\n", cmd.Stdout())
+		require.Equal(t, "echo *.log src/*", cmd.String())
+
+		err := ws.Cmd("echo", "*.md").ExpandGlobsWith(GlobFail).Error()
+		require.ErrorContains(t, err, "no matches found: *.md")
+	})
+}
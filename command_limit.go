@@ -0,0 +1,369 @@
+package types
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Limiter caps the number of processes running at the same time, globally and per group,
+// like a weighted semaphore. Commands wait for a slot in the order they asked for it,
+// until their context is done.
+//
+// A pipeline takes a slot for each of its processes, globally and in their groups, held by
+// its last command. Its processes can only run together, so a pipeline with more processes
+// than a limit takes the whole limit instead of failing. The source of Xargs, ForEachLine and
+// PipeParallel takes no slot, its sub-commands do. Commands streaming to each other
+// outside a pipeline, like a Tee source and its branches, hold slots at the same time,
+// the limits must allow them all to run.
+//
+// Example:
+//
+//	limiter := types.NewLimiter(16).Group("git", 4).Group("docker", 2)
+//	ctx := types.WithLimiter(context.Background(), limiter)
+//	output := types.Cmd("git", "fetch").WithContext(ctx).Stdout() // in the "git" group
+type Limiter struct {
+	mu     sync.Mutex
+	global *limitSemaphore
+	groups map[string]*limitSemaphore
+}
+
+// LimitOptions controls how a command uses a Limiter
+type LimitOptions struct {
+	// Group is the limiter group of the command, defaults to the program base name
+	Group string
+	// Weight is the number of slots the command takes, defaults to 1
+	Weight int
+}
+
+// LimiterStats reports the use of a Limiter or one of its groups
+type LimiterStats struct {
+	// Running is the weight of the commands holding slots
+	Running int
+	// Waiting is the number of commands waiting for a slot
+	Waiting int
+	// Acquired is the number of times a slot was granted
+	Acquired int
+	// TimedOut is the number of waits stopped by the command context
+	TimedOut int
+	// WaitTime is the total time commands waited for slots, MaxWait is the longest wait
+	WaitTime time.Duration
+	MaxWait  time.Duration
+	// Groups are the stats of each group, nil in group stats
+	Groups map[string]LimiterStats
+}
+
+// commandLimit is the limiter of a command and how it's used
+type commandLimit struct {
+	limiter *Limiter
+	opts    LimitOptions
+}
+
+// limitedProcess is a process waiting for limiter slots with the others of its pipeline
+type limitedProcess struct {
+	group  string
+	weight int
+}
+
+// limitSemaphore is a weighted semaphore granting slots in FIFO order
+type limitSemaphore struct {
+	// size is the number of slots, zero means unlimited
+	size    int
+	used    int
+	waiters list.List
+	stats   LimiterStats
+}
+
+// limitWaiter is a command waiting for slots, ready is closed when they're granted
+type limitWaiter struct {
+	weight int
+	ready  chan struct{}
+}
+
+// NewLimiter creates a Limiter running up to max processes at the same time, zero means
+// no global limit, only group limits.
+func NewLimiter(max int) *Limiter {
+	return &Limiter{
+		global: &limitSemaphore{size: max},
+		groups: map[string]*limitSemaphore{},
+	}
+}
+
+// Group limits the processes of the group name to max at the same time, within the global limit
+//
+// Example:
+//
+//	limiter := types.NewLimiter(0).Group("docker", 2)
+func (l *Limiter) Group(name string, max int) *Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if sem, ok := l.groups[name]; ok {
+		sem.size = max
+		sem.grant()
+	} else {
+		l.groups[name] = &limitSemaphore{size: max}
+	}
+
+	return l
+}
+
+// Stats returns the current use of the limiter and the wait times so far
+//
+// Example:
+//
+//	stats := limiter.Stats()
+//	fmt.Printf("git: %d running, %d waiting, waited %s\n", stats.Groups["git"].Running, stats.Groups["git"].Waiting, stats.Groups["git"].WaitTime)
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := l.global.snapshot()
+	stats.Groups = map[string]LimiterStats{}
+	for name, sem := range l.groups {
+		stats.Groups[name] = sem.snapshot()
+	}
+
+	return stats
+}
+
+// Limit makes the command wait for a slot of l before starting its process, in the group
+// of its program base name. It applies to the commands executed by this one that have no
+// limiter, like sequenced commands.
+//
+// Example:
+//
+//	err := types.Cmd("docker", "build", ".").Limit(limiter).Error()
+func (c *Command) Limit(l *Limiter) *Command {
+	return c.LimitWith(l, LimitOptions{})
+}
+
+// LimitWith is like Limit with a group and a weight
+//
+// Example:
+//
+//	err := types.Cmd("make", "-j8").LimitWith(limiter, types.LimitOptions{Group: "build", Weight: 8}).Error()
+func (c *Command) LimitWith(l *Limiter, opts LimitOptions) *Command {
+	c.limit = &commandLimit{limiter: l, opts: opts}
+	return c
+}
+
+// limiterKey is the context key of the limiter
+type limiterKey struct{}
+
+// WithLimiter returns a context carrying l, commands using the context without their own
+// limiter wait for its slots like with Limit.
+//
+// Example:
+//
+//	ctx := types.WithLimiter(context.Background(), limiter)
+//	output := types.Cmd("git", "status").WithContext(ctx).Stdout()
+func WithLimiter(ctx context.Context, l *Limiter) context.Context {
+	return context.WithValue(ctx, limiterKey{}, l)
+}
+
+// acquireLimit waits for a slot of the command limiter, release frees it. The processes
+// streaming to the command in a pipeline run under its slot, in their own groups.
+func (c *Command) acquireLimit() (release func(), err error) {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	limit := c.limit
+	if limit == nil {
+		limiter, ok := ctx.Value(limiterKey{}).(*Limiter)
+		if !ok {
+			return func() {}, nil
+		}
+		limit = &commandLimit{limiter: limiter}
+	}
+
+	var processes []limitedProcess
+	for cmd := c; cmd != nil; cmd = cmd.previous {
+		if cmd != c && (cmd.executed || cmd.cache != nil || !cmd.isProcess()) {
+			break
+		}
+
+		cmdLimit := cmd.limit
+		if cmdLimit == nil {
+			cmdLimit = limit
+		}
+		if cmdLimit.limiter == limit.limiter {
+			processes = append(processes, limitedProcess{group: cmdLimit.group(cmd), weight: cmdLimit.weight()})
+		}
+	}
+
+	return limit.limiter.acquire(ctx, processes)
+}
+
+// group returns the limiter group of cmd
+func (l *commandLimit) group(cmd *Command) string {
+	if l.opts.Group != "" {
+		return l.opts.Group
+	}
+	return filepath.Base(cmd.cmd)
+}
+
+// weight returns the number of slots the command takes
+func (l *commandLimit) weight() int {
+	return max(l.opts.Weight, 1)
+}
+
+// acquire waits for the slots of processes running together in each of their groups, by
+// name order, then for the global slots
+func (l *Limiter) acquire(ctx context.Context, processes []limitedProcess) (func(), error) {
+	start := time.Now()
+
+	type claim struct {
+		name   string
+		sem    *limitSemaphore
+		weight int
+	}
+
+	groups := map[string][]int{}
+	var weights []int
+	for _, process := range processes {
+		groups[process.group] = append(groups[process.group], process.weight)
+		weights = append(weights, process.weight)
+	}
+
+	l.mu.Lock()
+	claims := []claim{}
+	for _, name := range slices.Sorted(maps.Keys(groups)) {
+		if sem, ok := l.groups[name]; ok {
+			claims = append(claims, claim{name, sem, sem.claim(groups[name])})
+		}
+	}
+	claims = append(claims, claim{"", l.global, l.global.claim(weights)})
+	l.mu.Unlock()
+
+	for i, claim := range claims {
+		if err := l.wait(ctx, claim.sem, claim.weight, start); err != nil {
+			l.mu.Lock()
+			for _, held := range claims[:i] {
+				held.sem.used -= held.weight
+				held.sem.grant()
+			}
+			if claim.sem != l.global && ctx.Err() != nil {
+				l.global.stats.TimedOut++
+			}
+			l.mu.Unlock()
+
+			if claim.name == "" {
+				return nil, fmt.Errorf("waiting for a process slot: %w", err)
+			}
+			return nil, fmt.Errorf("waiting for a %q process slot: %w", claim.name, err)
+		}
+	}
+
+	return sync.OnceFunc(func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		for _, claim := range claims {
+			claim.sem.used -= claim.weight
+			claim.sem.grant()
+		}
+	}), nil
+}
+
+// wait blocks until sem grants weight slots or ctx is done
+func (l *Limiter) wait(ctx context.Context, sem *limitSemaphore, weight int, start time.Time) error {
+	l.mu.Lock()
+	if sem.size > 0 && weight > sem.size {
+		l.mu.Unlock()
+		return fmt.Errorf("weight %d exceeds the limit of %d", weight, sem.size)
+	}
+
+	if sem.waiters.Len() == 0 && sem.fits(weight) {
+		sem.used += weight
+		sem.acquired(time.Since(start))
+		l.mu.Unlock()
+		return nil
+	}
+
+	waiter := &limitWaiter{weight: weight, ready: make(chan struct{})}
+	element := sem.waiters.PushBack(waiter)
+	l.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		l.mu.Lock()
+		sem.acquired(time.Since(start))
+		l.mu.Unlock()
+		return nil
+
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		select {
+		case <-waiter.ready:
+			// the slots were granted while the context was done
+			sem.used -= weight
+		default:
+			sem.waiters.Remove(element)
+		}
+		sem.grant()
+		sem.stats.TimedOut++
+
+		return ctx.Err()
+	}
+}
+
+// claim returns the slots taken by processes running together: the sum of their weights,
+// up to the whole semaphore when they don't fit in it. A single process larger than the
+// semaphore still claims its weight, it can never run.
+func (s *limitSemaphore) claim(weights []int) int {
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+	if s.size == 0 || total <= s.size {
+		return total
+	}
+
+	return max(s.size, slices.Max(weights))
+}
+
+// fits reports whether weight slots are free
+func (s *limitSemaphore) fits(weight int) bool {
+	return s.size == 0 || s.used+weight <= s.size
+}
+
+// grant gives free slots to the waiters in order, a waiter that doesn't fit blocks the
+// ones after it so large weights aren't starved
+func (s *limitSemaphore) grant() {
+	for front := s.waiters.Front(); front != nil; front = s.waiters.Front() {
+		waiter := front.Value.(*limitWaiter)
+		if !s.fits(waiter.weight) {
+			return
+		}
+
+		s.used += waiter.weight
+		s.waiters.Remove(front)
+		close(waiter.ready)
+	}
+}
+
+// acquired records a granted wait
+func (s *limitSemaphore) acquired(wait time.Duration) {
+	s.stats.Acquired++
+	s.stats.WaitTime += wait
+	s.stats.MaxWait = max(s.stats.MaxWait, wait)
+}
+
+// snapshot returns the stats of the semaphore
+func (s *limitSemaphore) snapshot() LimiterStats {
+	stats := s.stats
+	stats.Running = s.used
+	stats.Waiting = s.waiters.Len()
+
+	return stats
+}
@@ -0,0 +1,204 @@
+package types
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("caps concurrent processes", func(t *testing.T) {
+		limiter := NewLimiter(2)
+
+		var mu sync.Mutex
+		running, peak := 0, 0
+		var wg sync.WaitGroup
+		for range 6 {
+			wg.Go(func() {
+				release, err := limiter.acquire(context.Background(), []limitedProcess{{"sleep", 1}})
+				require.NoError(t, err)
+				defer release()
+
+				mu.Lock()
+				running++
+				peak = max(peak, running)
+				mu.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+			})
+		}
+		wg.Wait()
+
+		require.Equal(t, 2, peak)
+		stats := limiter.Stats()
+		require.Equal(t, 6, stats.Acquired)
+		require.Zero(t, stats.Running)
+		require.Zero(t, stats.Waiting)
+		require.Positive(t, stats.WaitTime)
+		require.GreaterOrEqual(t, stats.MaxWait, 20*time.Millisecond)
+	})
+
+	t.Run("groups", func(t *testing.T) {
+		limiter := NewLimiter(0).Group("git", 1)
+
+		release, err := limiter.acquire(context.Background(), []limitedProcess{{"git", 1}})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = limiter.acquire(ctx, []limitedProcess{{"git", 1}})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.ErrorContains(t, err, `waiting for a "git" process slot`)
+
+		releaseDocker, err := limiter.acquire(context.Background(), []limitedProcess{{"docker", 1}})
+		require.NoError(t, err)
+
+		stats := limiter.Stats()
+		require.Equal(t, 2, stats.Running)
+		require.Equal(t, 1, stats.TimedOut)
+		require.Equal(t, 1, stats.Groups["git"].Running)
+		require.Equal(t, 1, stats.Groups["git"].TimedOut)
+
+		release()
+		releaseDocker()
+		release() // releasing twice has no effect
+		require.Zero(t, limiter.Stats().Running)
+	})
+
+	t.Run("waiters are served in order", func(t *testing.T) {
+		limiter := NewLimiter(2)
+
+		first, err := limiter.acquire(context.Background(), []limitedProcess{{"x", 1}})
+		require.NoError(t, err)
+
+		var mu sync.Mutex
+		var order []int
+		var wg sync.WaitGroup
+		for i, weight := range []int{2, 1} {
+			wg.Go(func() {
+				release, err := limiter.acquire(context.Background(), []limitedProcess{{"x", weight}})
+				require.NoError(t, err)
+				mu.Lock()
+				order = append(order, i)
+				mu.Unlock()
+				release()
+			})
+			require.Eventually(t, func() bool { return limiter.Stats().Waiting == i+1 }, time.Second, time.Millisecond)
+		}
+
+		first()
+		wg.Wait()
+		require.Equal(t, []int{0, 1}, order)
+	})
+
+	t.Run("weight larger than the limit", func(t *testing.T) {
+		_, err := NewLimiter(2).acquire(context.Background(), []limitedProcess{{"x", 3}})
+		require.ErrorContains(t, err, "weight 3 exceeds the limit of 2")
+	})
+
+	t.Run("commands wait for a slot", func(t *testing.T) {
+		limiter := NewLimiter(1)
+		release, err := limiter.acquire(context.Background(), []limitedProcess{{"other", 1}})
+		require.NoError(t, err)
+
+		cmd := Cmd("echo", "hello").Limit(limiter).WithTimeout(20 * time.Millisecond)
+		require.ErrorIs(t, cmd.Error(), context.DeadlineExceeded)
+		require.Empty(t, cmd.Stdout())
+
+		release()
+		require.Equal(t, "hello\n", Cmd("echo", "hello").Limit(limiter).Stdout())
+	})
+
+	t.Run("pipelines take a global slot for each process", func(t *testing.T) {
+		limiter := NewLimiter(3)
+		release, err := limiter.acquire(context.Background(), []limitedProcess{{"other", 1}})
+		require.NoError(t, err)
+
+		cmd := Cmd("echo", "hello").Pipe("cat").Pipe("tr", "a-z", "A-Z").Limit(limiter).WithTimeout(20 * time.Millisecond)
+		require.ErrorIs(t, cmd.Error(), context.DeadlineExceeded)
+		require.ErrorContains(t, cmd.Error(), "waiting for a process slot")
+
+		release()
+		cmd = Cmd("echo", "hello").Pipe("cat").Pipe("tr", "a-z", "A-Z").Limit(limiter)
+		require.Equal(t, "HELLO\n", cmd.Stdout())
+		require.Equal(t, 2, limiter.Stats().Acquired)
+	})
+
+	t.Run("pipelines longer than the limits take them whole", func(t *testing.T) {
+		limiter := NewLimiter(2).Group("cat", 1)
+
+		cmd := Cmd("echo", "hello").Pipe("cat").Pipe("cat").Pipe("cat").Limit(limiter)
+		require.Equal(t, "hello\n", cmd.Stdout())
+
+		release, err := limiter.acquire(context.Background(), []limitedProcess{{"cat", 1}})
+		require.NoError(t, err)
+		defer release()
+
+		cmd = Cmd("echo", "hello").Pipe("cat").Pipe("cat").Limit(limiter).WithTimeout(20 * time.Millisecond)
+		require.ErrorIs(t, cmd.Error(), context.DeadlineExceeded)
+		require.ErrorContains(t, cmd.Error(), `waiting for a "cat" process slot`)
+	})
+
+	t.Run("pipelines take a slot in each group", func(t *testing.T) {
+		limiter := NewLimiter(2).Group("echo", 1)
+		release, err := limiter.acquire(context.Background(), []limitedProcess{{"echo", 1}})
+		require.NoError(t, err)
+
+		cmd := Cmd("echo", "hello").Pipe("cat").Limit(limiter).WithTimeout(20 * time.Millisecond)
+		require.ErrorIs(t, cmd.Error(), context.DeadlineExceeded)
+		require.ErrorContains(t, cmd.Error(), `waiting for a "echo" process slot`)
+
+		release()
+		cmd = Cmd("echo", "hello").Pipe("cat").Limit(limiter)
+		require.Equal(t, "hello\n", cmd.Stdout())
+
+		stats := limiter.Stats()
+		require.Equal(t, 2, stats.Groups["echo"].Acquired)
+		require.Zero(t, stats.Groups["echo"].Running)
+	})
+
+	t.Run("fan-out sources take no slot", func(t *testing.T) {
+		limiter := NewLimiter(1)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// seq output doesn't fit in the pipe buffer, it can't finish before the sub-commands run
+		cmd := Cmd("seq", "1", "100000").Xargs(func(lines []string) *Command {
+			return Cmd("echo", lines[0]).WithContext(ctx)
+		}, XargsOptions{Batch: 1000}).Limit(limiter).WithContext(ctx)
+		require.NoError(t, cmd.Error())
+		require.Len(t, strings.Fields(cmd.Stdout()), 100)
+
+		cmd = Cmd("seq", "1", "100000").PipeParallelWith(2, PipeParallelOptions{Records: 1000}, "wc", "-l").Limit(limiter).WithContext(ctx)
+		require.NoError(t, cmd.Error())
+		require.Equal(t, slices.Repeat([]string{"1000"}, 100), strings.Fields(cmd.Stdout()))
+	})
+
+	t.Run("sequences and options", func(t *testing.T) {
+		limiter := NewLimiter(4).Group("build", 4)
+
+		cmd := Cmd("true").And(Cmd("echo", "done")).LimitWith(limiter, LimitOptions{Group: "build", Weight: 4})
+		require.Equal(t, "done\n", cmd.Stdout())
+
+		stats := limiter.Stats()
+		require.Equal(t, 2, stats.Acquired)
+		require.Equal(t, 2, stats.Groups["build"].Acquired)
+	})
+
+	t.Run("limiter from the context", func(t *testing.T) {
+		limiter := NewLimiter(1).Group("echo", 1)
+		ctx := WithLimiter(context.Background(), limiter)
+
+		require.Equal(t, "hello\n", Cmd("echo", "hello").WithContext(ctx).Stdout())
+		require.Equal(t, 1, limiter.Stats().Groups["echo"].Acquired)
+	})
+}
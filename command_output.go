@@ -0,0 +1,227 @@
+package types
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// OutputStream identifies the stream a chunk of output was written to
+type OutputStream int
+
+const (
+	// OutputStdout is the standard output stream
+	OutputStdout OutputStream = iota
+	// OutputStderr is the standard error stream
+	OutputStderr
+)
+
+// String returns "stdout" or "stderr"
+func (s OutputStream) String() string {
+	if s == OutputStderr {
+		return "stderr"
+	}
+
+	return "stdout"
+}
+
+// OutputChunk is a piece of output as the command wrote it
+type OutputChunk struct {
+	Stream OutputStream
+	// Time is when the chunk was captured
+	Time time.Time
+	Data []byte
+}
+
+// outputRecord is a write to stdout or stderr, chunks are rebuilt from the captured output
+type outputRecord struct {
+	stream OutputStream
+	time   time.Time
+	n      int
+}
+
+// timeline records the order of writes to stdout and stderr of a process
+type timeline struct {
+	mu      sync.Mutex
+	records []outputRecord
+}
+
+// recordWriter writes to a stream buffer and records the write in a timeline,
+// the timeline lock orders writes of both streams
+type recordWriter struct {
+	w        io.Writer
+	stream   OutputStream
+	timeline *timeline
+}
+
+func (r *recordWriter) Write(p []byte) (int, error) {
+	r.timeline.mu.Lock()
+	defer r.timeline.mu.Unlock()
+
+	n, err := r.w.Write(p)
+	if n > 0 {
+		r.timeline.records = append(r.timeline.records, outputRecord{stream: r.stream, time: time.Now(), n: n})
+	}
+
+	return n, err
+}
+
+// record returns a writer capturing the stream to w in the command timeline
+func (c *Command) record(stream OutputStream, w io.Writer) io.Writer {
+	return &recordWriter{w: w, stream: stream, timeline: c.timeline}
+}
+
+// Output executes the command and returns its stdout and stderr as chunks in the order
+// they were written, each tagged with its stream and capture time. Both streams are
+// captured concurrently, so the order is the one a terminal would show.
+//
+// Sequences, Xargs and PipeParallel return the chunks of the commands that ran, in the
+// order of their outputs. Output that wasn't captured from a process, like functions and
+// cached results, is returned as a stdout chunk then a stderr chunk with a zero Time.
+//
+// Example:
+//
+//	for _, chunk := range types.Cmd("make").Output() {
+//		fmt.Printf("%s %s: %s", chunk.Time.Format(time.TimeOnly), chunk.Stream, chunk.Data)
+//	}
+func (c *Command) Output() []OutputChunk {
+	c.execute()
+
+	// secrets are found in the whole streams, they may be split across chunks
+	var stdoutSecrets, stderrSecrets [][2]int
+	if c.redactOutput {
+		stdoutSecrets = c.secretRanges(c.stdout)
+		stderrSecrets = c.secretRanges(c.stderr)
+	}
+
+	var chunks []OutputChunk
+	var stdout, stderr int
+	for _, record := range c.records() {
+		chunk := OutputChunk{Stream: record.stream, Time: record.time}
+		if record.stream == OutputStderr {
+			chunk.Data = redactRange(c.stderr, stderr, stderr+record.n, stderrSecrets)
+			stderr += record.n
+		} else {
+			chunk.Data = redactRange(c.stdout, stdout, stdout+record.n, stdoutSecrets)
+			stdout += record.n
+		}
+		if len(chunk.Data) > 0 {
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	return chunks
+}
+
+// Combined executes the command and returns stdout and stderr interleaved the way a
+// terminal would have shown them, unlike StdoutStderr which returns stdout then stderr.
+//
+// Example:
+//
+//	log := types.Cmd("go", "build", "./...").Combined()
+func (c *Command) Combined() string {
+	c.execute()
+
+	var combined []byte
+	var stdout, stderr int
+	for _, record := range c.records() {
+		if record.stream == OutputStderr {
+			combined = append(combined, c.stderr[stderr:stderr+record.n]...)
+			stderr += record.n
+		} else {
+			combined = append(combined, c.stdout[stdout:stdout+record.n]...)
+			stdout += record.n
+		}
+	}
+
+	return string(c.output(combined))
+}
+
+// secretRanges returns the positions of the secret values in b, in order
+func (c *Command) secretRanges(b []byte) [][2]int {
+	values := c.secretRedactor().values
+	if !slices.ContainsFunc(values, func(value string) bool { return bytes.Contains(b, []byte(value)) }) {
+		return nil
+	}
+
+	var ranges [][2]int
+	for i := 0; i < len(b); {
+		// values are sorted longest first, like redact replaces them
+		j := slices.IndexFunc(values, func(value string) bool { return bytes.HasPrefix(b[i:], []byte(value)) })
+		if j < 0 {
+			i++
+			continue
+		}
+
+		ranges = append(ranges, [2]int{i, i + len(values[j])})
+		i += len(values[j])
+	}
+
+	return ranges
+}
+
+// redactRange returns b[start:end] with the secrets at ranges replaced. A secret is
+// replaced in the range it starts in and removed from the ranges it continues in.
+func redactRange(b []byte, start, end int, ranges [][2]int) []byte {
+	var redactedData []byte
+	pos := start
+	for _, secret := range ranges {
+		if secret[1] <= start || secret[0] >= end {
+			continue
+		}
+
+		if secret[0] >= start {
+			redactedData = append(redactedData, b[pos:secret[0]]...)
+			redactedData = append(redactedData, redacted...)
+		}
+		pos = min(secret[1], end)
+	}
+
+	if redactedData == nil {
+		return b[pos:end]
+	}
+
+	return append(redactedData, b[pos:end]...)
+}
+
+// records returns the timeline of the command output. When it's unknown or doesn't match
+// the captured output, it's stdout then stderr.
+func (c *Command) records() []outputRecord {
+	var records []outputRecord
+	switch {
+	case c.timeline != nil:
+		c.timeline.mu.Lock()
+		records = slices.Clone(c.timeline.records)
+		c.timeline.mu.Unlock()
+	case c.teeBranches != nil:
+		records = c.previous.records()
+	case c.seqOp != "" || c.xargs != nil || c.parallel != nil:
+		for _, cmd := range c.ran {
+			records = append(records, cmd.records()...)
+		}
+	}
+
+	var stdout, stderr int
+	for _, record := range records {
+		if record.stream == OutputStderr {
+			stderr += record.n
+		} else {
+			stdout += record.n
+		}
+	}
+	if stdout == len(c.stdout) && stderr == len(c.stderr) {
+		return records
+	}
+
+	records = nil
+	if len(c.stdout) > 0 {
+		records = append(records, outputRecord{stream: OutputStdout, n: len(c.stdout)})
+	}
+	if len(c.stderr) > 0 {
+		records = append(records, outputRecord{stream: OutputStderr, n: len(c.stderr)})
+	}
+
+	return records
+}
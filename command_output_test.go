@@ -0,0 +1,84 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Output(t *testing.T) {
+	// interleaved writes to both streams, sleeping so each write is captured separately
+	script := "echo one; sleep 0.05; echo warning >&2; sleep 0.05; echo two; sleep 0.05; echo error >&2"
+
+	// streams returns the stream and data of chunks
+	streams := func(chunks []OutputChunk) [][2]string {
+		var result [][2]string
+		for _, chunk := range chunks {
+			result = append(result, [2]string{chunk.Stream.String(), string(chunk.Data)})
+		}
+		return result
+	}
+
+	interleaved := [][2]string{{"stdout", "one\n"}, {"stderr", "warning\n"}, {"stdout", "two\n"}, {"stderr", "error\n"}}
+
+	t.Run("orders chunks of both streams", func(t *testing.T) {
+		start := time.Now()
+		cmd := Cmd("sh", "-c", script)
+
+		chunks := cmd.Output()
+		require.Equal(t, interleaved, streams(chunks))
+		require.Equal(t, "one\nwarning\ntwo\nerror\n", cmd.Combined())
+		require.Equal(t, "one\ntwo\nwarning\nerror\n", cmd.StdoutStderr())
+
+		for i, chunk := range chunks {
+			require.False(t, chunk.Time.Before(start))
+			if i > 0 {
+				require.True(t, chunk.Time.After(chunks[i-1].Time))
+			}
+		}
+	})
+
+	t.Run("piped commands", func(t *testing.T) {
+		producer := Cmd("sh", "-c", script)
+		consumer := producer.Pipe("cat")
+		require.Equal(t, "one\ntwo\n", consumer.Stdout())
+		require.Equal(t, "one\nwarning\ntwo\nerror\n", producer.Combined())
+	})
+
+	t.Run("started commands", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", script)
+		cmd.StdoutReader()
+		require.Equal(t, interleaved, streams(cmd.Output()))
+	})
+
+	t.Run("sequences concatenate commands output", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "echo a; sleep 0.05; echo b >&2").Then(Cmd("sh", "-c", "echo c >&2; sleep 0.05; echo d"))
+		require.Equal(t, "a\nb\nc\nd\n", cmd.Combined())
+	})
+
+	t.Run("functions return stdout then stderr", func(t *testing.T) {
+		cmd := CmdFn(func(string) (string, string, error) { return "out\n", "err\n", nil })
+		chunks := cmd.Output()
+		require.Equal(t, [][2]string{{"stdout", "out\n"}, {"stderr", "err\n"}}, streams(chunks))
+		require.True(t, chunks[0].Time.IsZero())
+		require.Equal(t, "out\nerr\n", cmd.Combined())
+	})
+
+	t.Run("no output", func(t *testing.T) {
+		require.Empty(t, Cmd("true").Output())
+		require.Empty(t, Cmd("true").Combined())
+	})
+
+	t.Run("redacts output when requested", func(t *testing.T) {
+		secret := "output-secret"
+		cmd := Cmd("sh", "-c", "echo $0; echo $0 >&2", secret).Secret(secret).RedactOutput()
+		require.Equal(t, "***\n***\n", cmd.Combined())
+		require.Equal(t, "***\n", string(cmd.Output()[1].Data))
+	})
+
+	t.Run("redacts secrets split across chunks", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "printf 'a split-'; sleep 0.05; echo warning >&2; sleep 0.05; printf 'secret b\n'").Secret("split-secret").RedactOutput()
+		require.Equal(t, [][2]string{{"stdout", "a ***"}, {"stderr", "warning\n"}, {"stdout", " b\n"}}, streams(cmd.Output()))
+	})
+}
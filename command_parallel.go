@@ -0,0 +1,176 @@
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PipeParallelOptions controls how PipeParallelWith splits its input into chunks
+type PipeParallelOptions struct {
+	// Records is the number of records in each chunk, defaults to 1000
+	Records int
+	// Delimiter terminates each record, defaults to "\n"
+	Delimiter string
+}
+
+// parallelStage holds the configuration of a parallel pipeline stage
+type parallelStage struct {
+	workers int
+	opts    PipeParallelOptions
+}
+
+// PipeParallel is like Pipe but splits this command's stdout into chunks of lines
+// and feeds each chunk to a new instance of the command, running up to n instances
+// at the same time. Outputs are merged back in the original order, so the stage
+// must process each chunk independently, like compressors, converters or filters.
+//
+// The configuration of the returned Command, like Dir, Env, AllowExitCodes or Retry,
+// applies to every instance. Its error joins the errors of all failed instances, and Ran
+// returns the instances in chunk order.
+//
+// Example:
+//
+//	matches := types.Cmd("cat", "huge.log").
+//		PipeParallel(runtime.NumCPU(), "grep", "-i", "error").
+//		Stdout()
+func (c *Command) PipeParallel(n int, cmd string, args ...string) *Command {
+	return c.PipeParallelWith(n, PipeParallelOptions{}, cmd, args...)
+}
+
+// PipeParallelWith is like PipeParallel with control over chunk size and record delimiter.
+//
+// Example:
+//
+//	output := types.Cmd("find", ".", "-print0").
+//		PipeParallelWith(4, types.PipeParallelOptions{Records: 100, Delimiter: "\x00"}, "xargs", "-0", "sha256sum").
+//		Stdout()
+func (c *Command) PipeParallelWith(n int, opts PipeParallelOptions, cmd string, args ...string) *Command {
+	next := c.Pipe(cmd, args...)
+	next.parallel = &parallelStage{workers: max(n, 1), opts: opts}
+
+	return next
+}
+
+// instance returns a new Command running the same system command with the same
+// configuration, reading its own input
+func (c *Command) instance() *Command {
+	instance := c.unexecuted()
+	instance.previous = nil
+	instance.input = nil
+	instance.parallel = nil
+	instance.cache = nil
+
+	return instance
+}
+
+// executeParallel splits the previous command output into chunks and runs an instance for each chunk
+func (c *Command) executeParallel() {
+	c.ran = c.ran[:0]
+	c.exitCode = 0
+
+	var source io.Reader = strings.NewReader("")
+	if c.previous != nil {
+		// The instances take the limiter slots, the previous command holding one while
+		// they wait for it could block them forever
+		c.previous.limitedDownstream = true
+		pipe, err := c.previous.getPipe(c.pipeFrom)
+		if err != nil {
+			c.err = err
+			return
+		}
+		source = pipe
+	} else if c.input != nil {
+		source = c.input
+	}
+
+	records := c.parallel.opts.Records
+	if records < 1 {
+		records = 1000
+	}
+
+	delimiter := []byte(c.parallel.opts.Delimiter)
+	if len(delimiter) == 0 {
+		delimiter = []byte("\n")
+	}
+
+	chunks := make(chan string)
+	var scanErr error
+	go func() {
+		defer close(chunks)
+
+		scanner := bufio.NewScanner(source)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		scanner.Split(splitAfter(delimiter))
+
+		var chunk strings.Builder
+		count := 0
+		for scanner.Scan() {
+			chunk.Write(scanner.Bytes())
+			count++
+			if count == records {
+				chunks <- chunk.String()
+				chunk.Reset()
+				count = 0
+			}
+		}
+
+		if count > 0 {
+			chunks <- chunk.String()
+		}
+
+		if scanErr = scanner.Err(); scanErr != nil {
+			// The rest of the input is never read, a previous command writing it would block
+			if closer, ok := source.(io.Closer); ok && c.previous != nil {
+				closer.Close()
+			}
+		}
+	}()
+
+	// The workers copy a template, c itself is written while they run
+	tmpl := c.instance()
+	results := OrderedParallelizeChan(chunks, c.parallel.workers, func(in <-chan string) <-chan *Command {
+		return ChanMap(in, func(chunk string) *Command {
+			return tmpl.unexecuted().Input(chunk).Run()
+		})
+	})
+
+	var stdout, stderr bytes.Buffer
+	var errs []error
+	for instance := range results {
+		i := len(c.ran)
+		c.ran = append(c.ran, instance)
+		stdout.Write(instance.stdout)
+		stderr.Write(instance.stderr)
+		if instance.err != nil {
+			errs = append(errs, fmt.Errorf("chunk %d: %w", i, instance.err))
+			c.exitCode = instance.exitCode
+		}
+	}
+
+	c.stdout = stdout.Bytes()
+	c.stderr = stderr.Bytes()
+	c.err = errors.Join(append(errs, scanErr)...)
+}
+
+// splitAfter returns a bufio.SplitFunc that splits records after delimiter, keeping it in the token
+func splitAfter(delimiter []byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		if i := bytes.Index(data, delimiter); i >= 0 {
+			return i + len(delimiter), data[:i+len(delimiter)], nil
+		}
+
+		if atEOF {
+			return len(data), data, nil
+		}
+
+		return 0, nil, nil
+	}
+}
@@ -0,0 +1,117 @@
+package types
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_PipeParallel(t *testing.T) {
+	t.Run("merges output in original order", func(t *testing.T) {
+		cmd := Cmd("seq", "1", "10000").PipeParallelWith(4, PipeParallelOptions{Records: 100}, "sed", "s/^/n/")
+
+		expected := Cmd("seq", "1", "10000").Pipe("sed", "s/^/n/").Stdout()
+		require.Equal(t, expected, cmd.Stdout())
+		require.NoError(t, cmd.Error())
+		require.Len(t, cmd.Ran(), 100)
+	})
+
+	t.Run("default chunk size", func(t *testing.T) {
+		cmd := Cmd("seq", "1", "2500").PipeParallel(2, "wc", "-l")
+		require.Equal(t, []string{"1000", "1000", "500"}, strings.Fields(cmd.Stdout()))
+	})
+
+	t.Run("keeps order when later chunks finish first", func(t *testing.T) {
+		cmd := Cmd("printf", "3\n2\n1\n").PipeParallelWith(3, PipeParallelOptions{Records: 1},
+			"sh", "-c", "read n; sleep 0.0$n; echo $n")
+
+		require.Equal(t, "3\n2\n1\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("runs instances in parallel", func(t *testing.T) {
+		script := `read record; ` + barrier("$record", 4) + `; echo "$record"`
+		cmd := Cmd("seq", "1", "4").PipeParallelWith(4, PipeParallelOptions{Records: 1}, "sh", "-c", script, t.TempDir())
+
+		require.Equal(t, "1\n2\n3\n4\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("custom delimiter", func(t *testing.T) {
+		cmd := Cmd("printf", "a\\0b\\0c").PipeParallelWith(2, PipeParallelOptions{Records: 1, Delimiter: "\x00"}, "tr", "a-z", "A-Z")
+		require.Equal(t, "A\x00B\x00C", cmd.Stdout())
+	})
+
+	t.Run("last record without delimiter", func(t *testing.T) {
+		cmd := Cmd("printf", "a\nb").PipeParallelWith(2, PipeParallelOptions{Records: 1}, "cat")
+		require.Equal(t, "a\nb", cmd.Stdout())
+	})
+
+	t.Run("instances inherit configuration", func(t *testing.T) {
+		cmd := Cmd("printf", "x\ny\n").
+			PipeParallelWith(2, PipeParallelOptions{Records: 1}, "sh", "-c", "cat; echo $MY_VAR").
+			Env("MY_VAR", "set")
+
+		require.Equal(t, "x\nset\ny\nset\n", cmd.Stdout())
+	})
+
+	t.Run("instances inherit allowed exit codes", func(t *testing.T) {
+		cmd := Cmd("printf", "error: a\nok\nerror: b\n").
+			PipeParallelWith(2, PipeParallelOptions{Records: 1}, "grep", "error").
+			AllowExitCodes(0, 1)
+
+		require.NoError(t, cmd.Error())
+		require.Equal(t, "error: a\nerror: b\n", cmd.Stdout())
+		require.Equal(t, 1, cmd.Ran()[1].ExitCode())
+	})
+
+	t.Run("reports failed chunks", func(t *testing.T) {
+		cmd := Cmd("printf", "ok\nfail\nok\n").PipeParallelWith(2, PipeParallelOptions{Records: 1},
+			"sh", "-c", `read l; [ "$l" = ok ]`)
+
+		require.EqualError(t, cmd.Error(), "chunk 1: exit status 1")
+		require.Equal(t, 1, cmd.ExitCode())
+	})
+
+	t.Run("retries run every chunk again", func(t *testing.T) {
+		cmd := Cmd("printf", "a\nb\n").PipeParallelWith(2, PipeParallelOptions{Records: 1}, "false").Retry(2)
+
+		require.Len(t, cmd.Ran(), 2)
+		require.EqualError(t, cmd.Error(), "chunk 0: exit status 1\nchunk 1: exit status 1")
+	})
+
+	t.Run("records too long stop the previous command", func(t *testing.T) {
+		done := filepath.Join(t.TempDir(), "done")
+		source := Cmd("sh", "-c", "(head -c 20000000 /dev/zero | tr '\\0' a; echo; seq 1 100000); touch "+done)
+		cmd := source.PipeParallel(2, "cat")
+
+		require.ErrorIs(t, cmd.Error(), bufio.ErrTooLong)
+		// the previous command stays blocked writing unless its output is closed
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(done)
+			return err == nil
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("previous error propagates", func(t *testing.T) {
+		cmd := Cmd("nonexistent-command-xyz").PipeParallel(2, "cat")
+		require.Error(t, cmd.Error())
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		cmd := Cmd("true").PipeParallel(2, "cat")
+		require.Empty(t, cmd.Stdout())
+		require.NoError(t, cmd.Error())
+		require.Empty(t, cmd.Ran())
+	})
+
+	t.Run("can be piped", func(t *testing.T) {
+		cmd := Cmd("seq", "1", "100").PipeParallelWith(4, PipeParallelOptions{Records: 7}, "cat").Pipe("wc", "-l")
+		require.Equal(t, "100", cmd.StdoutTrimmed())
+	})
+}
@@ -0,0 +1,195 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// script holds the body of an inline script and how it's passed to the interpreter
+type script struct {
+	body string
+	// caller is the Go file and line that created the script
+	caller string
+	// strict prepends shell strict mode to the body
+	strict bool
+	// viaStdin passes the body on stdin instead of a temporary file
+	viaStdin bool
+	// path is the temporary file of the running script
+	path string
+}
+
+// Script creates a Command that runs body with an interpreter like bash, sh, python3,
+// ruby or node, with args as positional arguments ($1, sys.argv[1], ...). The body is
+// written to a temporary file that is removed after execution, so the script can still
+// read stdin from Input or Pipe. It composes with Dir, Env and other options like Cmd.
+//
+// When the script fails, the error tells the Go file and line that created it and
+// the script line reported by the interpreter, if any.
+//
+// Example:
+//
+//	output := types.Script("bash", `
+//		for f in "$@"; do
+//			wc -l "$f"
+//		done
+//	`, "a.txt", "b.txt").Strict().Dir("/tmp").Stdout()
+func Script(interpreter, body string, args ...string) *Command {
+	return newScript(interpreter, body, args)
+}
+
+// PipeScript chains a Script to receive this command's stdout as stdin.
+//
+// Example:
+//
+//	report := types.Cmd("git", "log", "--format=%an").PipeScript("python3", `
+//	import sys, collections
+//	for author, count in collections.Counter(sys.stdin.read().split("\n")).most_common(3):
+//	    print(count, author)
+//	`).Stdout()
+func (c *Command) PipeScript(interpreter, body string, args ...string) *Command {
+	next := newScript(interpreter, body, args)
+	next.previous = c
+
+	return next
+}
+
+// newScript creates a script Command, it must be called directly by the exported constructors
+// so the caller is the Go code creating the script
+func newScript(interpreter, body string, args []string) *Command {
+	caller := "unknown"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		caller = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+
+	c := Cmd(interpreter, args...)
+	c.script = &script{body: body, caller: caller}
+
+	return c
+}
+
+// Strict enables strict mode for shell scripts: exit on errors and undefined variables,
+// and on failures in any pipeline stage for shells supporting it (bash, zsh, ksh).
+// It has no effect on other interpreters or commands not created by Script.
+//
+// Example:
+//
+//	err := types.Script("bash", "false | true\necho unreachable").Strict().Error()
+func (c *Command) Strict() *Command {
+	if c.script != nil {
+		c.script.strict = true
+	}
+	return c
+}
+
+// ScriptViaStdin passes the script body to the interpreter on stdin instead of a temporary
+// file, the script then can't read stdin itself, Input and pipes are ignored.
+// It has no effect on commands not created by Script.
+//
+// Example:
+//
+//	output := types.Script("python3", "import sys\nprint(sys.argv[1])", "hello").ScriptViaStdin().Stdout()
+func (c *Command) ScriptViaStdin() *Command {
+	if c.script != nil {
+		c.script.viaStdin = true
+	}
+	return c
+}
+
+// interpreterName returns the base name of the interpreter without version, e.g. "python" for /usr/bin/python3.11
+func (s *script) interpreterName(interpreter string) string {
+	return strings.TrimRight(filepath.Base(interpreter), "0123456789.")
+}
+
+// prelude returns the strict mode lines prepended to the body
+func (s *script) prelude(interpreter string) string {
+	if !s.strict {
+		return ""
+	}
+
+	switch s.interpreterName(interpreter) {
+	case "bash", "zsh", "ksh":
+		return "set -euo pipefail\n"
+	case "sh", "dash", "ash":
+		return "set -eu\n"
+	default:
+		return ""
+	}
+}
+
+// prepareScript writes the script for command and inserts the script argument after the interpreter
+func (c *Command) prepareScript(command *exec.Cmd) error {
+	body := c.script.prelude(c.cmd) + c.script.body
+
+	var arg string
+	if c.script.viaStdin {
+		arg = "-"
+		if slices.Contains([]string{"sh", "bash", "zsh", "ksh", "dash", "ash"}, c.script.interpreterName(c.cmd)) {
+			arg = "-s"
+		}
+		command.Stdin = strings.NewReader(body)
+	} else {
+		file, err := os.CreateTemp("", "script-*")
+		if err != nil {
+			return err
+		}
+		c.script.path = file.Name()
+
+		_, err = file.WriteString(body)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			c.cleanupScript()
+			return err
+		}
+		arg = c.script.path
+	}
+
+	// the script goes right after the interpreter, the positional arguments may be
+	// expanded to any number of arguments
+	at := 1
+	if c.useSudo {
+		at = 2
+	}
+	command.Args = slices.Insert(command.Args, at, arg)
+
+	return nil
+}
+
+// cleanupScript removes the temporary file of the script
+func (c *Command) cleanupScript() {
+	if c.script.path != "" {
+		os.Remove(c.script.path)
+		c.script.path = ""
+	}
+}
+
+// scriptLinePattern matches line numbers in interpreters errors: "line 3" (bash, python) or ": 3:" (sh, dash)
+var scriptLinePattern = regexp.MustCompile(`\bline (\d+)|: (\d+): `)
+
+// scriptError adds the script location to err, using the last line number reported on stderr
+func (c *Command) scriptError(err error, stderr []byte) error {
+	if err == nil {
+		return nil
+	}
+
+	location := "script at " + c.script.caller
+	offset := strings.Count(c.script.prelude(c.cmd), "\n")
+	matches := scriptLinePattern.FindAllSubmatch(stderr, -1)
+	if len(matches) > 0 {
+		last := matches[len(matches)-1]
+		number := string(last[1]) + string(last[2])
+		if line, convErr := strconv.Atoi(number); convErr == nil && line > offset {
+			location += fmt.Sprintf(", line %d", line-offset)
+		}
+	}
+
+	return fmt.Errorf("%s: %w", location, err)
+}
@@ -0,0 +1,167 @@
+package types
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScript(t *testing.T) {
+	t.Run("runs body with positional arguments", func(t *testing.T) {
+		cmd := Script("bash", `
+			for arg in "$@"; do
+				echo "arg: $arg"
+			done
+		`, "one", "two words")
+
+		require.Equal(t, "arg: one\narg: two words\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("reads stdin from input and pipes", func(t *testing.T) {
+		require.Equal(t, "HELLO\n", Cmd("echo", "hello").PipeScript("sh", "tr a-z A-Z").Stdout())
+		require.Equal(t, "WORLD", Script("sh", "tr a-z A-Z").Input("world").Stdout())
+	})
+
+	t.Run("piped script error locates the script", func(t *testing.T) {
+		err := Cmd("echo").PipeScript("sh", "exit 2").Error()
+		require.Regexp(t, `^script at command_script_test\.go:\d+: exit status 2$`, err.Error())
+	})
+
+	t.Run("can be piped", func(t *testing.T) {
+		cmd := Script("sh", "echo b; echo a").Pipe("sort")
+		require.Equal(t, "a\nb\n", cmd.Stdout())
+	})
+
+	t.Run("composes with Dir and Env", func(t *testing.T) {
+		dir := t.TempDir()
+		cmd := Script("sh", `echo "$(pwd) $GREETING"`).Dir(dir).Env("GREETING", "hi")
+		require.Equal(t, dir+" hi\n", cmd.Stdout())
+	})
+
+	t.Run("composes with ExpandGlobs", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"a.txt", "b.txt"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("echo I am "+name), 0o644))
+		}
+
+		cmd := Script("sh", `echo "$@"`, "*.txt").Dir(dir).ExpandGlobs()
+		require.Equal(t, "a.txt b.txt\n", cmd.Stdout())
+	})
+
+	t.Run("removes the temporary file", func(t *testing.T) {
+		cmd := Script("sh", `echo "$0"`)
+		path := strings.TrimSpace(cmd.Stdout())
+		require.NotEmpty(t, path)
+
+		_, err := os.Stat(path)
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("python", func(t *testing.T) {
+		if _, err := exec.LookPath("python3"); err != nil {
+			t.Skip("python3 is not installed")
+		}
+
+		cmd := Script("python3", "import sys\nprint(' '.join(sys.argv[1:]))", "a", "b")
+		require.Equal(t, "a b\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("via stdin", func(t *testing.T) {
+		cmd := Script("bash", `echo "$1-$2"`, "x", "y").ScriptViaStdin()
+		require.Equal(t, "x-y\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("python via stdin", func(t *testing.T) {
+		if _, err := exec.LookPath("python3"); err != nil {
+			t.Skip("python3 is not installed")
+		}
+
+		cmd := Script("python3", "import sys\nprint(sys.argv[1])", "arg").ScriptViaStdin()
+		require.Equal(t, "arg\n", cmd.Stdout())
+	})
+
+	t.Run("without strict mode errors are ignored", func(t *testing.T) {
+		cmd := Script("bash", "false\necho after")
+		require.Equal(t, "after\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("strict mode stops on errors", func(t *testing.T) {
+		cmd := Script("bash", "false\necho after").Strict()
+		require.Empty(t, cmd.Stdout())
+		require.Error(t, cmd.Error())
+		require.Equal(t, 1, cmd.ExitCode())
+	})
+
+	t.Run("strict mode catches pipeline failures", func(t *testing.T) {
+		cmd := Script("bash", "false | true\necho after").Strict()
+		require.Error(t, cmd.Error())
+	})
+
+	t.Run("strict mode catches undefined variables", func(t *testing.T) {
+		cmd := Script("sh", "echo $UNDEFINED_VARIABLE_XYZ").Strict()
+		require.Error(t, cmd.Error())
+	})
+
+	t.Run("error locates the script", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			interpreter string
+			strict      bool
+		}{
+			{name: "bash", interpreter: "bash"},
+			{name: "bash strict", interpreter: "bash", strict: true},
+			{name: "sh", interpreter: "sh"},
+			{name: "sh strict", interpreter: "sh", strict: true},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				cmd := Script(tt.interpreter, "echo ok\nmissing-command-xyz\nexit 3")
+				if tt.strict {
+					cmd.Strict()
+				}
+
+				err := cmd.Error()
+				require.Error(t, err)
+				require.Regexp(t, `^script at command_script_test\.go:\d+, line (2|3): exit status`, err.Error())
+				require.Contains(t, err.Error(), "line 2:")
+			})
+		}
+	})
+
+	t.Run("python error locates the script", func(t *testing.T) {
+		if _, err := exec.LookPath("python3"); err != nil {
+			t.Skip("python3 is not installed")
+		}
+
+		cmd := Script("python3", "x = 1\nraise SystemExit(undefined_name)")
+		require.Regexp(t, `^script at command_script_test\.go:\d+, line 2: exit status 1$`, cmd.Error().Error())
+	})
+
+	t.Run("string", func(t *testing.T) {
+		require.Equal(t, "bash <script> a b", Script("bash", "echo", "a", "b").String())
+	})
+
+	t.Run("strict has no effect on commands", func(t *testing.T) {
+		cmd := Cmd("echo", "hello").Strict().ScriptViaStdin()
+		require.Equal(t, "hello\n", cmd.Stdout())
+	})
+
+	t.Run("sudo inserts the script after the interpreter", func(t *testing.T) {
+		cmd := Script("bash", "echo", "a").Sudo()
+		command := exec.Command("sudo", "bash", "a")
+		require.NoError(t, cmd.prepareScript(command))
+		defer cmd.cleanupScript()
+
+		require.Equal(t, []string{"sudo", "bash", cmd.script.path, "a"}, command.Args)
+		require.Equal(t, os.TempDir(), filepath.Dir(cmd.script.path))
+	})
+}
@@ -0,0 +1,174 @@
+package types
+
+import (
+	"bytes"
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// redacted replaces secret values in commands rendering, errors and transcripts
+const redacted = "***"
+
+// Secret marks values as secrets of this command, they're replaced by *** wherever the
+// command shows them: String, errors and recorded transcripts. The secrets of the commands
+// it's made of, like earlier commands of a pipeline, are replaced too. Use RedactOutput to
+// also scrub them from the output.
+//
+// Example:
+//
+//	cmd := types.Cmd("mysql", "--user=admin", "--password="+password).Secret(password)
+//	fmt.Println(cmd) // mysql --user=admin --password=***
+func (c *Command) Secret(values ...string) *Command {
+	for _, value := range values {
+		if value != "" {
+			c.secrets = append(c.secrets, value)
+		}
+	}
+
+	return c
+}
+
+// RedactEnv marks the values of environment variables as secrets for this command,
+// whether they are set with Env, loaded with EnvFile or inherited, see Secret. The values
+// are read when the command is executed.
+//
+// Example:
+//
+//	err := types.Cmd("aws", "s3", "ls").
+//		Env("AWS_SECRET_ACCESS_KEY", key).
+//		RedactEnv("AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN").
+//		Error() // *** instead of the key if the error contains it
+func (c *Command) RedactEnv(keys ...string) *Command {
+	c.redactEnv = append(c.redactEnv, keys...)
+	return c
+}
+
+// RedactOutput replaces secret values in the output returned by Stdout, Stderr and their
+// variants. Piped commands and StdoutReader still receive the original output.
+//
+// Example:
+//
+//	config := types.Cmd("env").Env("TOKEN", token).RedactEnv("TOKEN").RedactOutput().Stdout() // TOKEN=***
+func (c *Command) RedactOutput() *Command {
+	c.redactOutput = true
+	return c
+}
+
+// redactor replaces the secret values of a command
+type redactor struct {
+	// values are sorted longest first so a secret containing another one is fully replaced
+	values   []string
+	replacer *strings.Replacer
+}
+
+// newRedactor collects the values marked with Secret and the environment values of
+// RedactEnv keys of every command this command is made of
+func (c *Command) newRedactor() *redactor {
+	var values []string
+	seen := map[*Command]bool{}
+	var visit func(cmd *Command)
+	visit = func(cmd *Command) {
+		if cmd == nil || seen[cmd] {
+			return
+		}
+		seen[cmd] = true
+
+		for _, child := range slices.Concat([]*Command{cmd.previous, cmd.seqLeft, cmd.seqRight}, cmd.teeBranches) {
+			visit(child)
+		}
+
+		values = append(values, cmd.secrets...)
+		if cmd.redactEnv == nil {
+			return
+		}
+
+		env, err := cmd.environ()
+		if err != nil {
+			env = cmd.env
+		}
+		for _, key := range cmd.redactEnv {
+			if value := env[key]; value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	visit(c)
+
+	r := &redactor{values: values}
+	if len(values) == 0 {
+		return r
+	}
+
+	slices.SortFunc(r.values, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	pairs := make([]string, 0, len(values)*2)
+	for _, value := range r.values {
+		pairs = append(pairs, value, redacted)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+
+	return r
+}
+
+// secretRedactor returns the redactor built when the command was executed, or a new one
+// if it wasn't executed yet
+func (c *Command) secretRedactor() *redactor {
+	if c.redactor != nil {
+		return c.redactor
+	}
+
+	return c.newRedactor()
+}
+
+// redact replaces the secret values in s
+func (c *Command) redact(s string) string {
+	r := c.secretRedactor()
+	if r.replacer == nil {
+		return s
+	}
+
+	return r.replacer.Replace(s)
+}
+
+// redactBytes replaces the secret values in b, it returns b itself when it contains none
+func (c *Command) redactBytes(b []byte) []byte {
+	values := c.secretRedactor().values
+	if !slices.ContainsFunc(values, func(value string) bool { return bytes.Contains(b, []byte(value)) }) {
+		return b
+	}
+
+	return []byte(c.redact(string(b)))
+}
+
+// output returns the captured output, with secrets replaced when RedactOutput is set
+func (c *Command) output(b []byte) []byte {
+	if !c.redactOutput {
+		return b
+	}
+
+	return c.redactBytes(b)
+}
+
+// redactErr returns err with secrets replaced in its message, it still wraps err
+func (c *Command) redactErr(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	message := c.redact(err.Error())
+	if message == err.Error() {
+		return err
+	}
+
+	return &redactedError{message: message, err: err}
+}
+
+// redactedError is an error with secrets replaced in its message,
+// errors.Is and errors.As still see the original error
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string { return e.message }
+func (e *redactedError) Unwrap() error { return e.err }
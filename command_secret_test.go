@@ -0,0 +1,96 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Secret(t *testing.T) {
+	token := "s3cr3t-token"
+
+	t.Run("redacts rendering", func(t *testing.T) {
+		cmd := Cmd("curl", "-H", "Authorization: Bearer "+token, "https://example.com").Secret(token)
+		require.Equal(t, "curl -H Authorization: Bearer *** https://example.com", cmd.String())
+		require.Equal(t, "echo *** && cat", Cmd("echo", token).Secret(token).And(Cmd("cat")).String())
+	})
+
+	t.Run("only redacts the command it belongs to", func(t *testing.T) {
+		Cmd("echo", "e").Secret("e")
+		require.Equal(t, "echo hello", Cmd("echo", "hello").String())
+		require.Equal(t, "echo "+token, Cmd("echo", token).String())
+	})
+
+	t.Run("ignores empty values", func(t *testing.T) {
+		require.Equal(t, "echo hello", Cmd("echo", "hello").Secret("").String())
+	})
+
+	t.Run("passes the real value to the command", func(t *testing.T) {
+		require.Equal(t, "s3cr3t-token\n", Cmd("echo", token).Secret(token).Stdout())
+	})
+
+	t.Run("redacts errors and keeps wrapping them", func(t *testing.T) {
+		err := Cmd("sh", "-c", "exit 2").ExitCodeMeanings(map[int]string{2: "bad token " + token}).Secret(token).Error()
+		require.EqualError(t, err, "exit status 2: bad token ***")
+
+		var exitErr *exec.ExitError
+		require.True(t, errors.As(err, &exitErr))
+	})
+
+	t.Run("redacts errors of piped commands", func(t *testing.T) {
+		cmd := Cmd("echo", token).Secret(token).Pipe("sh", "-c", "exit 3").ExitCodeMeanings(map[int]string{3: "bad " + token})
+		require.EqualError(t, cmd.Error(), "exit status 3: bad ***")
+	})
+
+	t.Run("redacts output when requested", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "echo out $0; echo err $0 >&2", token).Secret(token).RedactOutput()
+		require.Equal(t, "out ***\n", cmd.Stdout())
+		require.Equal(t, "err ***\n", cmd.Stderr())
+		require.Equal(t, "out ***\nerr ***\n", cmd.StdoutStderr())
+		require.Equal(t, "OUT S3CR3T-TOKEN\n", Cmd("echo", "out", token).Secret(token).RedactOutput().Pipe("tr", "a-z", "A-Z").Stdout())
+	})
+}
+
+func TestCommand_RedactEnv(t *testing.T) {
+	t.Run("redacts values set with Env", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", `echo "$KEY"; echo "key $KEY" >&2; exit 1`).
+			Env("KEY", "env-secret-value").
+			RedactEnv("KEY").
+			ExitCodeMeanings(map[int]string{1: "rejected env-secret-value"})
+
+		require.EqualError(t, cmd.Error(), "exit status 1: rejected ***")
+		require.Equal(t, "env-secret-value\n", cmd.Stdout())
+		require.Equal(t, "key ***\n", cmd.RedactOutput().Stderr())
+	})
+
+	t.Run("redacts inherited values", func(t *testing.T) {
+		t.Setenv("INHERITED_SECRET", "inherited-secret-value")
+		cmd := Cmd("echo", "inherited-secret-value").RedactEnv("INHERITED_SECRET")
+		require.Equal(t, "echo ***", cmd.String())
+		require.Equal(t, "echo inherited-secret-value", Cmd("echo", "inherited-secret-value").RedactEnv("INHERITED_SECRET").ClearEnv().String())
+	})
+
+	t.Run("values are collected once per execution", func(t *testing.T) {
+		t.Setenv("ROTATED_SECRET", "first-secret-value")
+		cmd := Cmd("echo", "first-secret-value").RedactEnv("ROTATED_SECRET").Run()
+
+		t.Setenv("ROTATED_SECRET", "second-secret-value")
+		require.Equal(t, "echo ***", cmd.String())
+	})
+
+	t.Run("redacts values of piped commands", func(t *testing.T) {
+		cmd := Cmd("printenv", "KEY").Env("KEY", "piped-secret").RedactEnv("KEY").Pipe("sh", "-c", "read key; echo $key >&2; exit 3")
+		require.EqualError(t, cmd.ExitCodeMeanings(map[int]string{3: "piped-secret"}).Error(), "exit status 3: ***")
+	})
+
+	t.Run("redacts wait errors", func(t *testing.T) {
+		_, err := WaitUntilWith(context.Background(), Cmd("echo", "wait-secret").Env("KEY", "wait-secret").RedactEnv("KEY"), func(*Command) bool { return false }, WaitOptions{Timeout: 10 * time.Millisecond})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.NotContains(t, err.Error(), "wait-secret")
+		require.Contains(t, err.Error(), "waiting for echo ***")
+	})
+}
@@ -0,0 +1,22 @@
+package types
+
+// ForwardSignals makes the Go program survive the signals meant for the command while
+// it runs, like a shell does for its foreground job. SIGINT, SIGTERM, SIGHUP and SIGWINCH
+// received by the program are forwarded to the command instead of stopping the program.
+//
+// The command runs in its own process group, which also becomes the terminal foreground
+// group in interactive mode, so keys like Ctrl-C reach it exactly once. The terminal is
+// given back to the program when the command exits. When the command is killed by a
+// signal its exit code is 128 plus the signal number, like in a shell. On systems without
+// process groups the program only ignores interrupts while the command runs.
+//
+// Example:
+//
+//	cmd := types.Cmd("psql", "mydb").Interactive().ForwardSignals()
+//	if err := cmd.Error(); err != nil {
+//		os.Exit(cmd.ExitCode()) // 130 if psql was interrupted
+//	}
+func (c *Command) ForwardSignals() *Command {
+	c.forwardSignals = true
+	return c
+}
@@ -0,0 +1,26 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd || dragonfly)
+
+package types
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+// signalForwarder makes the program ignore interrupts while the command runs, the
+// console or terminal delivers them to the command directly
+type signalForwarder struct {
+	signals chan os.Signal
+}
+
+func prepareSignals(command *exec.Cmd) *signalForwarder {
+	f := &signalForwarder{signals: make(chan os.Signal, 1)}
+	signal.Notify(f.signals, os.Interrupt)
+
+	return f
+}
+
+func (f *signalForwarder) start(process *os.Process) {}
+
+func (f *signalForwarder) stop() { signal.Stop(f.signals) }
@@ -0,0 +1,58 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package types
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_ForwardSignals(t *testing.T) {
+	// signalWhenReady sends sig to the test process once the command created the ready file
+	signalWhenReady := func(ready string, sig syscall.Signal) {
+		go func() {
+			for {
+				if _, err := os.Stat(ready); err == nil {
+					syscall.Kill(os.Getpid(), sig)
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+	}
+
+	t.Run("forwards signals to the command", func(t *testing.T) {
+		ready := filepath.Join(t.TempDir(), "ready")
+		signalWhenReady(ready, syscall.SIGINT)
+
+		cmd := Cmd("sh", "-c", `trap 'echo got INT; kill $!; exit 3' INT; touch "$0"; sleep 5 & wait`, ready).ForwardSignals()
+		require.Error(t, cmd.Error())
+		require.Equal(t, "got INT\n", cmd.Stdout())
+		require.Equal(t, 3, cmd.ExitCode())
+	})
+
+	t.Run("exit code of a command killed by a signal", func(t *testing.T) {
+		ready := filepath.Join(t.TempDir(), "ready")
+		signalWhenReady(ready, syscall.SIGTERM)
+
+		start := time.Now()
+		cmd := Cmd("sh", "-c", `touch "$0"; exec sleep 5`, ready).ForwardSignals()
+		require.EqualError(t, cmd.Error(), "signal: terminated")
+		require.Equal(t, 128+int(syscall.SIGTERM), cmd.ExitCode())
+		require.Less(t, time.Since(start), 4*time.Second)
+	})
+
+	t.Run("runs the command in its own process group", func(t *testing.T) {
+		var pgid, pid int
+		_, err := fmt.Sscan(Cmd("sh", "-c", "ps -o pgid= -p $$; echo $$").ForwardSignals().Stdout(), &pgid, &pid)
+		require.NoError(t, err)
+		require.Equal(t, pid, pgid)
+		require.NotEqual(t, syscall.Getpgrp(), pgid)
+	})
+}
@@ -0,0 +1,93 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package types
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// forwardedSignals are the signals ForwardSignals forwards to the command
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGWINCH}
+
+// signalForwarder forwards the program signals to a command process group
+type signalForwarder struct {
+	signals chan os.Signal
+	done    chan struct{}
+	// terminal is the file descriptor of the terminal given to the command, -1 if none
+	terminal int
+}
+
+// prepareSignals puts command in its own process group, in the terminal foreground if
+// it uses the terminal as stdin while the program is in the foreground, and starts
+// catching the signals so none stops the program before the command starts.
+func prepareSignals(command *exec.Cmd) *signalForwarder {
+	f := &signalForwarder{
+		signals:  make(chan os.Signal, len(forwardedSignals)),
+		done:     make(chan struct{}),
+		terminal: -1,
+	}
+
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.Setpgid = true
+
+	if command.Stdin == os.Stdin {
+		fd := int(os.Stdin.Fd())
+		if group, err := foregroundGroup(fd); err == nil && group == syscall.Getpgrp() {
+			command.SysProcAttr.Foreground = true
+			command.SysProcAttr.Ctty = fd
+			f.terminal = fd
+		}
+	}
+
+	signal.Notify(f.signals, forwardedSignals...)
+
+	return f
+}
+
+// start forwards the caught signals to the process group of the started process
+func (f *signalForwarder) start(process *os.Process) {
+	go func() {
+		for {
+			select {
+			case sig := <-f.signals:
+				syscall.Kill(-process.Pid, sig.(syscall.Signal))
+			case <-f.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop restores the default handling of the signals and gives the terminal back to the program
+func (f *signalForwarder) stop() {
+	signal.Stop(f.signals)
+	close(f.done)
+
+	if f.terminal < 0 {
+		return
+	}
+
+	// a background process group changing the foreground group gets SIGTTOU unless it ignores it
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+
+	group := int32(syscall.Getpgrp())
+	syscall.Syscall(syscall.SYS_IOCTL, uintptr(f.terminal), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&group)))
+}
+
+// foregroundGroup returns the foreground process group of the terminal fd
+func foregroundGroup(fd int) (int, error) {
+	var group int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&group)))
+	if errno != 0 {
+		return 0, errno
+	}
+
+	return int(group), nil
+}
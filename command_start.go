@@ -0,0 +1,149 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os/exec"
+)
+
+// running holds the state of a command started with StdinWriter or StdoutReader
+type running struct {
+	// stdin writes to the command stdin, nil if stdin comes from Input or a pipe
+	stdin io.WriteCloser
+	// stdout streams the command stdout as it's produced
+	stdout io.Reader
+	// done is closed when the command exits and its output is captured
+	done chan struct{}
+}
+
+// StdinWriter starts the command and returns a writer to its stdin, to stream input
+// to long-running processes like sqlite3, bc or language servers. Closing the writer
+// sends EOF to the command. Input and InputReader are ignored. Piped commands read the
+// previous command output, the returned writer fails every write for them.
+//
+// Read responses incrementally with StdoutReader. Stdout is buffered without limit,
+// so writing never deadlocks when responses are not read. Output methods like Stdout
+// and Error wait for the command to exit.
+//
+// StdinWriter must be called before the command is executed or started by StdoutReader,
+// otherwise the returned writer fails every write. Calling it again returns the same writer.
+// The started command runs once, Retry doesn't apply to it.
+//
+// Example:
+//
+//	bc := types.Cmd("bc")
+//	stdin, stdout := bc.StdinWriter(), bufio.NewReader(bc.StdoutReader())
+//	fmt.Fprintln(stdin, "1+2")
+//	answer, _ := stdout.ReadString('\n') // "3\n"
+//	stdin.Close()
+//	err := bc.Error()
+func (c *Command) StdinWriter() io.WriteCloser {
+	if c.previous != nil {
+		return failingWriter{err: errors.New("stdin writer can't be used on a piped command")}
+	}
+
+	c.start(true)
+
+	if c.running == nil || c.running.stdin == nil {
+		return failingWriter{err: errors.New("stdin writer must be requested before the command starts")}
+	}
+
+	return c.running.stdin
+}
+
+// StdoutReader starts the command if it's not executed yet and returns a reader
+// streaming its stdout as it's produced. Stdout is still captured for Stdout.
+// For a command executed by other methods it returns a reader of the captured stdout.
+// Only system commands can be streamed, other commands are executed first. A started
+// command runs once, Retry doesn't apply to it.
+//
+// Example:
+//
+//	logs := bufio.NewScanner(types.Cmd("kubectl", "logs", "-f", "my-pod").StdoutReader())
+//	for logs.Scan() {
+//		fmt.Println(logs.Text())
+//	}
+func (c *Command) StdoutReader() io.Reader {
+	c.start(false)
+
+	if c.running == nil {
+		return bytes.NewReader(c.execute().stdout)
+	}
+
+	return c.running.stdout
+}
+
+// start runs a system command in the background, with stdin connected to a writer if withStdin
+func (c *Command) start(withStdin bool) {
+	if c.running != nil || c.executed || !c.isProcess() {
+		return
+	}
+
+	c.executed = true
+	c.redactor = c.newRedactor()
+	c.running = &running{done: make(chan struct{})}
+
+	c.propagate()
+
+	var command *exec.Cmd
+	release, err := c.acquireLimit()
+	if err == nil {
+		command, err = c.buildCommand()
+	}
+	if err == nil && withStdin {
+		command.Stdin = nil
+		c.running.stdin, err = command.StdinPipe()
+	}
+
+	stdoutReader, stdoutWriter := newBufferedPipe()
+	c.running.stdout = stdoutReader
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	if err == nil {
+		command.Stdout = io.MultiWriter(c.record(OutputStdout, &stdoutBuf), stdoutWriter)
+		command.Stderr = c.record(OutputStderr, &stderrBuf)
+		err = c.startProcess(command)
+	}
+
+	if err != nil {
+		if release != nil {
+			release()
+		}
+		c.err = err
+		if command != nil {
+			c.writeAudit(command)
+		}
+		if c.running.stdin == nil && withStdin {
+			c.running.stdin = failingWriter{err: err}
+		}
+		stdoutWriter.Close()
+		close(c.running.done)
+		return
+	}
+
+	go func() {
+		err := c.waitProcess(command)
+		release()
+		c.stdout = stdoutBuf.Bytes()
+		c.stderr = stderrBuf.Bytes()
+		c.setErr(err)
+		c.writeAudit(command)
+		stdoutWriter.Close()
+		close(c.running.done)
+	}()
+}
+
+// wait blocks until a started command exits
+func (c *Command) wait() {
+	if c.running != nil {
+		<-c.running.done
+	}
+}
+
+// failingWriter fails all writes with err
+type failingWriter struct{ err error }
+
+func (f failingWriter) Write([]byte) (int, error) { return 0, f.err }
+
+func (f failingWriter) Close() error { return nil }
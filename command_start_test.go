@@ -0,0 +1,164 @@
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_StdinWriter(t *testing.T) {
+	t.Run("request and response", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", `while read line; do echo "got $line"; done`)
+		stdin := cmd.StdinWriter()
+		stdout := bufio.NewReader(cmd.StdoutReader())
+
+		for _, request := range []string{"one", "two", "three"} {
+			_, err := fmt.Fprintln(stdin, request)
+			require.NoError(t, err)
+
+			response, err := stdout.ReadString('\n')
+			require.NoError(t, err)
+			require.Equal(t, "got "+request+"\n", response)
+		}
+
+		require.NoError(t, stdin.Close())
+		require.NoError(t, cmd.Error())
+		require.Equal(t, "got one\ngot two\ngot three\n", cmd.Stdout())
+
+		_, err := stdout.ReadString('\n')
+		require.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("close sends EOF", func(t *testing.T) {
+		cmd := Cmd("wc", "-l")
+		stdin := cmd.StdinWriter()
+		_, err := io.WriteString(stdin, "a\nb\n")
+		require.NoError(t, err)
+		require.NoError(t, stdin.Close())
+
+		require.Equal(t, "2", cmd.StdoutTrimmed())
+	})
+
+	t.Run("unread output doesn't block writing", func(t *testing.T) {
+		cmd := Cmd("cat")
+		stdin := cmd.StdinWriter()
+
+		data := bytes.Repeat([]byte("0123456789\n"), 1024*1024)
+		done := make(chan error)
+		go func() {
+			_, err := stdin.Write(data)
+			stdin.Close()
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("writing to stdin blocked")
+		}
+
+		require.NoError(t, cmd.Error())
+		require.Equal(t, data, cmd.StdoutBytes())
+	})
+
+	t.Run("returns the same writer", func(t *testing.T) {
+		cmd := Cmd("cat")
+		stdin := cmd.StdinWriter()
+		require.Same(t, stdin, cmd.StdinWriter())
+		stdin.Close()
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("exit code of started command", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "read line; exit $line")
+		stdin := cmd.StdinWriter()
+		fmt.Fprintln(stdin, "4")
+		stdin.Close()
+
+		require.Error(t, cmd.Error())
+		require.Equal(t, 4, cmd.ExitCode())
+	})
+
+	t.Run("fails after execution", func(t *testing.T) {
+		cmd := Cmd("true").Run()
+		_, err := cmd.StdinWriter().Write([]byte("data"))
+		require.EqualError(t, err, "stdin writer must be requested before the command starts")
+	})
+
+	t.Run("start error", func(t *testing.T) {
+		cmd := Cmd("nonexistent-command-xyz")
+		_, err := cmd.StdinWriter().Write([]byte("data"))
+		require.Error(t, err)
+		require.Error(t, cmd.Error())
+	})
+
+	t.Run("fails for piped commands", func(t *testing.T) {
+		cmd := Cmd("echo", "hello").Pipe("cat")
+		_, err := cmd.StdinWriter().Write([]byte("data"))
+		require.EqualError(t, err, "stdin writer can't be used on a piped command")
+		require.Equal(t, "hello\n", cmd.Stdout())
+	})
+
+	t.Run("piped from a started command", func(t *testing.T) {
+		source := Cmd("cat")
+		stdin := source.StdinWriter()
+		cmd := source.Pipe("tr", "a-z", "A-Z")
+
+		io.WriteString(stdin, "hello\n")
+		stdin.Close()
+		require.Equal(t, "HELLO\n", cmd.Stdout())
+	})
+}
+
+func TestCommand_StdoutReader(t *testing.T) {
+	t.Run("streams output as it's produced", func(t *testing.T) {
+		// the second line is written only after the first one is read, it gives up after 10 seconds
+		read := filepath.Join(t.TempDir(), "read")
+		cmd := Cmd("sh", "-c", `echo first; i=0
+while [ ! -e "$0" ]; do i=$((i + 1)); [ $i -gt 1000 ] && exit 1; sleep 0.01; done
+echo second`, read)
+		reader := bufio.NewReader(cmd.StdoutReader())
+
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "first\n", line)
+		require.NoError(t, os.WriteFile(read, nil, 0o644))
+
+		rest, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, "second\n", string(rest))
+		require.Equal(t, "first\nsecond\n", cmd.Stdout())
+	})
+
+	t.Run("uses Input as stdin", func(t *testing.T) {
+		cmd := Cmd("cat").Input("from input")
+		data, err := io.ReadAll(cmd.StdoutReader())
+		require.NoError(t, err)
+		require.Equal(t, "from input", string(data))
+	})
+
+	t.Run("executed command", func(t *testing.T) {
+		cmd := Cmd("echo", "cached").Run()
+		data, err := io.ReadAll(cmd.StdoutReader())
+		require.NoError(t, err)
+		require.Equal(t, "cached\n", string(data))
+	})
+
+	t.Run("function command is executed", func(t *testing.T) {
+		cmd := CmdFn(func(stdin string) (string, string, error) {
+			return strings.ToUpper(stdin), "", nil
+		}).Input("hello")
+		data, err := io.ReadAll(cmd.StdoutReader())
+		require.NoError(t, err)
+		require.Equal(t, "HELLO", string(data))
+	})
+}
@@ -0,0 +1,19 @@
+//go:build !(linux || darwin || freebsd || openbsd || netbsd || dragonfly)
+
+package types
+
+import "os"
+
+// terminalInput reads the terminal, a read waiting for input can't be interrupted
+type terminalInput struct {
+	terminal *os.File
+}
+
+func newTerminalInput(terminal *os.File) *terminalInput {
+	return &terminalInput{terminal: terminal}
+}
+
+func (t *terminalInput) Read(p []byte) (int, error) { return t.terminal.Read(p) }
+
+// stop does nothing, the read in progress consumes the next input
+func (t *terminalInput) stop(reading <-chan struct{}) {}
@@ -0,0 +1,63 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+
+package types
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// terminalInput reads the terminal through a non-blocking duplicate of its file
+// descriptor, so a read waiting for input can be interrupted when the command exits
+type terminalInput struct {
+	terminal *os.File
+	file     *os.File
+	// nonblocking is the mode of the terminal file description before reading, it's
+	// shared with the duplicate
+	nonblocking bool
+}
+
+func newTerminalInput(terminal *os.File) *terminalInput {
+	input := &terminalInput{terminal: terminal}
+
+	fd := int(terminal.Fd())
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_GETFL, 0)
+	if errno != 0 {
+		return input
+	}
+	input.nonblocking = flags&syscall.O_NONBLOCK != 0
+
+	dup, err := syscall.Dup(fd)
+	if err != nil {
+		return input
+	}
+	if err := syscall.SetNonblock(dup, true); err != nil {
+		syscall.Close(dup)
+		return input
+	}
+	input.file = os.NewFile(uintptr(dup), terminal.Name())
+
+	return input
+}
+
+func (t *terminalInput) Read(p []byte) (int, error) {
+	if t.file == nil {
+		return t.terminal.Read(p)
+	}
+
+	return t.file.Read(p)
+}
+
+// stop interrupts the read in progress, waits for reading to be closed and restores the
+// terminal mode
+func (t *terminalInput) stop(reading <-chan struct{}) {
+	if t.file == nil || t.file.SetReadDeadline(time.Now()) != nil {
+		// reads can't be interrupted, the next input is consumed by the reader
+		return
+	}
+	<-reading
+
+	t.file.Close()
+	syscall.SetNonblock(int(t.terminal.Fd()), t.nonblocking)
+}
@@ -0,0 +1,231 @@
+package types
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// TeePolicy controls how Tee deals with branches that consume output at different speeds
+type TeePolicy int
+
+const (
+	// TeeBlock writes every chunk to all branches before reading the next one,
+	// so the slowest branch limits the producer and memory usage stays constant.
+	TeeBlock TeePolicy = iota
+	// TeeBuffer queues unread output in memory for each branch,
+	// so a slow branch never slows down the producer or the other branches.
+	TeeBuffer
+)
+
+// Tee streams this command's stdout to all branches concurrently, similar to
+// `producer | tee >(branch1) >(branch2)` in bash. The producer runs only once.
+//
+// Each branch may be a single command or a pipeline, its first command receives
+// the stream as stdin. Branches keep their own results, query them directly after
+// the returned command is executed. Branches that exit early stop receiving data
+// without affecting the others.
+//
+// The returned Command passes the producer's output through so it can be piped further.
+// Its error joins the producer error with all branches errors.
+//
+// Tee uses the TeeBlock policy, use TeeWith to choose another one.
+//
+// Example:
+//
+//	compressed := types.Cmd("gzip").Pipe("wc", "-c")
+//	checksum := types.Cmd("sha256sum")
+//	err := types.Cmd("pg_dump", "mydb").Tee(compressed, checksum).Error()
+//	size, sum := compressed.StdoutTrimmed(), checksum.StdoutTrimmed()
+func (c *Command) Tee(branches ...*Command) *Command {
+	return c.TeeWith(TeeBlock, branches...)
+}
+
+// TeeWith is like Tee with a backpressure policy.
+//
+// Example:
+//
+//	err := types.Cmd("pg_dump", "mydb").TeeWith(types.TeeBuffer, types.Cmd("gzip"), types.Cmd("sha256sum")).Error()
+func (c *Command) TeeWith(policy TeePolicy, branches ...*Command) *Command {
+	return &Command{
+		previous:    c,
+		teeBranches: append([]*Command{}, branches...),
+		teePolicy:   policy,
+	}
+}
+
+// executeTee reads the previous command stdout and copies it to all branches
+func (c *Command) executeTee() {
+	source, err := c.previous.getStdoutPipe()
+	if err != nil {
+		c.err = err
+		return
+	}
+
+	writers := make([]io.WriteCloser, len(c.teeBranches))
+	var wg sync.WaitGroup
+	for i, branch := range c.teeBranches {
+		var r io.ReadCloser
+		if c.teePolicy == TeeBuffer {
+			r, writers[i] = newBufferedPipe()
+		} else {
+			r, writers[i] = io.Pipe()
+		}
+
+		root := branch
+		for root.previous != nil {
+			root = root.previous
+		}
+		root.input = r
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			branch.execute()
+			// unblock writes to a branch that exited without reading all its input
+			r.Close()
+		}()
+	}
+
+	fanout := &teeWriter{writers: writers}
+	_, copyErr := io.Copy(fanout, source)
+	fanout.Close()
+	wg.Wait()
+
+	c.stdout = c.previous.stdout
+	c.stderr = c.previous.stderr
+	c.exitCode = c.previous.exitCode
+
+	errs := []error{c.previous.err}
+	if c.previous.err == nil {
+		errs[0] = copyErr
+	}
+	for _, branch := range c.teeBranches {
+		errs = append(errs, branch.err)
+	}
+	c.err = errors.Join(errs...)
+}
+
+// teeString renders the tee command, e.g. "tee >(gzip) >(sha256sum)"
+func (c *Command) teeString() string {
+	parts := []string{"tee"}
+	for _, branch := range c.teeBranches {
+		parts = append(parts, ">("+branch.String()+")")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// teeWriter writes to all writers, writers that fail are closed and dropped
+// so one branch exiting early doesn't stop the others.
+type teeWriter struct {
+	writers []io.WriteCloser
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	for i, w := range t.writers {
+		if w == nil {
+			continue
+		}
+
+		if _, err := w.Write(p); err != nil {
+			w.Close()
+			t.writers[i] = nil
+		}
+	}
+
+	return len(p), nil
+}
+
+func (t *teeWriter) Close() error {
+	for _, w := range t.writers {
+		if w != nil {
+			w.Close()
+		}
+	}
+
+	return nil
+}
+
+// bufferedPipe is an in-memory pipe with an unbounded buffer, writes never block.
+type bufferedPipe struct {
+	mu         sync.Mutex
+	cond       *sync.Cond
+	buf        []byte
+	readClosed bool
+	// writeClosed makes reads return io.EOF once the buffer is drained
+	writeClosed bool
+}
+
+func newBufferedPipe() (*bufferedPipeReader, *bufferedPipeWriter) {
+	p := &bufferedPipe{}
+	p.cond = sync.NewCond(&p.mu)
+
+	return &bufferedPipeReader{p}, &bufferedPipeWriter{p}
+}
+
+type bufferedPipeReader struct{ p *bufferedPipe }
+
+func (r *bufferedPipeReader) Read(b []byte) (int, error) {
+	p := r.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.buf) == 0 && !p.writeClosed && !p.readClosed {
+		p.cond.Wait()
+	}
+
+	if p.readClosed {
+		return 0, io.ErrClosedPipe
+	}
+
+	if len(p.buf) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(b, p.buf)
+	p.buf = p.buf[n:]
+
+	return n, nil
+}
+
+func (r *bufferedPipeReader) Close() error {
+	p := r.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.readClosed = true
+	p.buf = nil
+	p.cond.Broadcast()
+
+	return nil
+}
+
+type bufferedPipeWriter struct{ p *bufferedPipe }
+
+func (w *bufferedPipeWriter) Write(b []byte) (int, error) {
+	p := w.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.readClosed || p.writeClosed {
+		return 0, io.ErrClosedPipe
+	}
+
+	p.buf = append(p.buf, b...)
+	p.cond.Broadcast()
+
+	return len(b), nil
+}
+
+func (w *bufferedPipeWriter) Close() error {
+	p := w.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.writeClosed = true
+	p.cond.Broadcast()
+
+	return nil
+}
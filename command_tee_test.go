@@ -0,0 +1,135 @@
+package types
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Tee(t *testing.T) {
+	policies := []struct {
+		name   string
+		policy TeePolicy
+	}{
+		{name: "block", policy: TeeBlock},
+		{name: "buffer", policy: TeeBuffer},
+	}
+
+	for _, p := range policies {
+		t.Run(p.name, func(t *testing.T) {
+			t.Run("every branch receives the full output", func(t *testing.T) {
+				count := Cmd("wc", "-l")
+				sum := Cmd("grep", "-c", "5")
+				cmd := Cmd("seq", "1", "10000").TeeWith(p.policy, count, sum)
+
+				require.NoError(t, cmd.Error())
+				require.Equal(t, "10000", count.StdoutTrimmed())
+				require.Equal(t, "3439", sum.StdoutTrimmed())
+			})
+
+			t.Run("branches can be pipelines", func(t *testing.T) {
+				branch := Cmd("grep", "0$").Pipe("wc", "-l")
+				cmd := Cmd("seq", "1", "100").TeeWith(p.policy, branch)
+
+				require.NoError(t, cmd.Error())
+				require.Equal(t, "10", branch.StdoutTrimmed())
+			})
+
+			t.Run("branch exiting early doesn't stop the others", func(t *testing.T) {
+				head := Cmd("head", "-n", "1")
+				count := Cmd("wc", "-l")
+				cmd := Cmd("seq", "1", "200000").TeeWith(p.policy, head, count)
+
+				require.NoError(t, cmd.Error())
+				require.Equal(t, "1\n", head.Stdout())
+				require.Equal(t, "200000", count.StdoutTrimmed())
+			})
+		})
+	}
+
+	t.Run("producer runs only once", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "count")
+		producer := Cmd("sh", "-c", "echo x >> "+file+"; echo data")
+		a, b := Cmd("cat"), Cmd("cat")
+
+		require.NoError(t, producer.Tee(a, b).Error())
+		require.Equal(t, "data\n", a.Stdout())
+		require.Equal(t, "data\n", b.Stdout())
+
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.Equal(t, "x\n", string(content))
+	})
+
+	t.Run("passes producer output through", func(t *testing.T) {
+		cmd := Cmd("echo", "hello").Tee(Cmd("cat")).Pipe("tr", "a-z", "A-Z")
+		require.Equal(t, "HELLO\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("producer error is reported", func(t *testing.T) {
+		branch := Cmd("cat")
+		cmd := Cmd("sh", "-c", "echo partial; exit 3").Tee(branch)
+
+		require.Error(t, cmd.Error())
+		require.Equal(t, 3, cmd.ExitCode())
+		require.Equal(t, "partial\n", cmd.Stdout())
+		require.Equal(t, "partial\n", branch.Stdout())
+	})
+
+	t.Run("branch error is reported", func(t *testing.T) {
+		ok := Cmd("cat")
+		failing := Cmd("sh", "-c", "cat > /dev/null; exit 2")
+		cmd := Cmd("echo", "hello").Tee(ok, failing)
+
+		require.Error(t, cmd.Error())
+		require.NoError(t, ok.Error())
+		require.Equal(t, 2, failing.ExitCode())
+	})
+
+	t.Run("function branches", func(t *testing.T) {
+		branch := CmdFn(func(stdin string) (string, string, error) {
+			return strings.ToUpper(stdin), "", nil
+		})
+		require.NoError(t, Cmd("echo", "hello").Tee(branch).Error())
+		require.Equal(t, "HELLO\n", branch.Stdout())
+	})
+
+	t.Run("no branches", func(t *testing.T) {
+		cmd := Cmd("echo", "hello").Tee()
+		require.Equal(t, "hello\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("string", func(t *testing.T) {
+		cmd := Cmd("pg_dump", "db").Tee(Cmd("gzip"), Cmd("sha256sum"))
+		require.Equal(t, "tee >(gzip) >(sha256sum)", cmd.String())
+	})
+}
+
+func TestBufferedPipe(t *testing.T) {
+	t.Run("writes don't block and reads drain the buffer", func(t *testing.T) {
+		r, w := newBufferedPipe()
+		for range 1000 {
+			_, err := w.Write([]byte("data"))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, strings.Repeat("data", 1000), string(data))
+	})
+
+	t.Run("writes fail after reader closed", func(t *testing.T) {
+		r, w := newBufferedPipe()
+		require.NoError(t, r.Close())
+
+		_, err := w.Write([]byte("data"))
+		require.ErrorIs(t, err, io.ErrClosedPipe)
+	})
+}
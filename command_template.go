@@ -0,0 +1,309 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// CommandTemplate is a command line with text/template placeholders. The command line is
+// split into arguments before any placeholder is filled, so values never split into
+// several arguments or inject shell operators, whatever they contain.
+type CommandTemplate struct {
+	text  string
+	words []templateWord
+}
+
+// templateWord is an argument of a command template, or a range producing arguments
+type templateWord struct {
+	// tmpl renders the argument, nil for ranges
+	tmpl *template.Template
+	// keep is true when the argument has content outside if and with blocks, it's kept even if empty
+	keep bool
+	// items calls collect for each element of a range
+	items *template.Template
+	// body are the arguments produced for each element of a range
+	body []templateWord
+}
+
+// CmdTemplate parses a command line template. Arguments are separated by spaces outside of
+// placeholders and quotes, single quotes keep text as is and double quotes support \" and \\
+// escapes and placeholders, like in a shell.
+//
+// Each argument is rendered with text/template and always produces one argument, even an
+// empty one, except arguments made of if and with blocks only that render empty, which are
+// dropped so {{if .Verbose}}-v{{end}} works. A {{range PIPELINE}}...{{end}} block produces the
+// arguments of its body for every element, the element is the dot in the body.
+// if and with blocks must be within a single argument.
+//
+// Example:
+//
+//	gitLog, err := types.CmdTemplate("git log --author={{.Author}} {{.Range}} -- {{range .Files}}{{.}}{{end}}")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	cmd, err := gitLog.Cmd(map[string]any{
+//		"Author": "Jane; rm -rf /",
+//		"Range":  "main..feature",
+//		"Files":  []string{"a.go", "my file.go"},
+//	})
+//	// git log "--author=Jane; rm -rf /" main..feature -- a.go "my file.go"
+func CmdTemplate(text string) (*CommandTemplate, error) {
+	words, err := parseTemplateWords(text)
+	if err != nil {
+		return nil, fmt.Errorf("command template %q: %w", text, err)
+	}
+
+	return &CommandTemplate{text: text, words: words}, nil
+}
+
+// Cmd fills the placeholders with data and returns the command
+//
+// Example:
+//
+//	cmd, err := tmpl.Cmd(struct{ Author, Range string }{"jane", "v1..v2"})
+//	output := cmd.Stdout()
+func (t *CommandTemplate) Cmd(data any) (*Command, error) {
+	args, err := renderTemplateWords(t.words, data)
+	if err != nil {
+		return nil, fmt.Errorf("command template %q: %w", t.text, err)
+	}
+
+	if len(args) == 0 || args[0] == "" {
+		return nil, fmt.Errorf("command template %q: no command", t.text)
+	}
+
+	return Cmd(args[0], args[1:]...), nil
+}
+
+// renderTemplateWords renders words with data to arguments
+func renderTemplateWords(words []templateWord, data any) ([]string, error) {
+	var args []string
+	for _, word := range words {
+		if word.items != nil {
+			var items []any
+			collect, err := word.items.Clone()
+			if err != nil {
+				return nil, err
+			}
+			collect.Funcs(template.FuncMap{"collect": func(item any) string {
+				items = append(items, item)
+				return ""
+			}})
+			if err := collect.Execute(&strings.Builder{}, data); err != nil {
+				return nil, err
+			}
+
+			for _, item := range items {
+				itemArgs, err := renderTemplateWords(word.body, item)
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, itemArgs...)
+			}
+			continue
+		}
+
+		var arg strings.Builder
+		if err := word.tmpl.Execute(&arg, data); err != nil {
+			return nil, err
+		}
+		if arg.Len() > 0 || word.keep {
+			args = append(args, arg.String())
+		}
+	}
+
+	return args, nil
+}
+
+// newWordTemplate creates a template for a command template argument
+func newWordTemplate(text string) (*template.Template, error) {
+	return template.New("arg").Option("missingkey=error").Funcs(template.FuncMap{"collect": func(any) string { return "" }}).Parse(text)
+}
+
+// parseTemplateWords splits a command line template into arguments and ranges
+func parseTemplateWords(text string) ([]templateWord, error) {
+	var words []templateWord
+
+	for i := 0; i < len(text); {
+		if unicode.IsSpace(rune(text[i])) {
+			i++
+			continue
+		}
+
+		if pipeline, ok := rangeAction(text[i:]); ok {
+			word, end, err := parseTemplateRange(text[i:], pipeline)
+			if err != nil {
+				return nil, err
+			}
+			words = append(words, word)
+			i += end
+			continue
+		}
+
+		var src strings.Builder
+		// content inside if and with blocks is conditional, it doesn't make the argument kept
+		keep := false
+		depth := 0
+		for i < len(text) && !unicode.IsSpace(rune(text[i])) {
+			switch {
+			case strings.HasPrefix(text[i:], "{{"):
+				end := strings.Index(text[i:], "}}")
+				if end < 0 {
+					return nil, errors.New("unclosed action")
+				}
+				if _, ok := rangeAction(text[i:]); ok {
+					return nil, errors.New("range must start an argument")
+				}
+				action := text[i+2 : i+end]
+				keep = keep || depth == 0 && blockDepth(action) == 0 && !isTemplateComment(action)
+				depth += blockDepth(action)
+				src.WriteString(text[i : i+end+2])
+				i += end + 2
+
+			case text[i] == '\'':
+				end := strings.IndexByte(text[i+1:], '\'')
+				if end < 0 {
+					return nil, errors.New("unterminated single quote")
+				}
+				src.WriteString(literalTemplateText(text[i+1 : i+1+end]))
+				keep = keep || depth == 0
+				i += end + 2
+
+			case text[i] == '"':
+				i++
+				keep = keep || depth == 0
+				for {
+					if i >= len(text) {
+						return nil, errors.New("unterminated double quote")
+					}
+					if text[i] == '"' {
+						i++
+						break
+					}
+					if strings.HasPrefix(text[i:], "{{") {
+						end := strings.Index(text[i:], "}}")
+						if end < 0 {
+							return nil, errors.New("unclosed action")
+						}
+						src.WriteString(text[i : i+end+2])
+						i += end + 2
+						continue
+					}
+					if text[i] == '\\' && i+1 < len(text) && (text[i+1] == '"' || text[i+1] == '\\') {
+						i++
+					}
+					src.WriteString(literalTemplateText(text[i : i+1]))
+					i++
+				}
+
+			default:
+				src.WriteString(literalTemplateText(text[i : i+1]))
+				keep = keep || depth == 0
+				i++
+			}
+		}
+
+		tmpl, err := newWordTemplate(src.String())
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, templateWord{tmpl: tmpl, keep: keep})
+	}
+
+	return words, nil
+}
+
+// parseTemplateRange parses a range block at the start of text up to its matching end
+// action, it returns the range and the length of the block
+func parseTemplateRange(text, pipeline string) (templateWord, int, error) {
+	first := strings.Index(text, "}}") + 2
+	depth := 1
+	for i := first; i < len(text); {
+		start := strings.Index(text[i:], "{{")
+		if start < 0 {
+			break
+		}
+		start += i
+		end := strings.Index(text[start:], "}}")
+		if end < 0 {
+			return templateWord{}, 0, errors.New("unclosed action")
+		}
+		end += start + 2
+
+		depth += blockDepth(text[start+2 : end-2])
+
+		if depth == 0 {
+			if end < len(text) && !unicode.IsSpace(rune(text[end])) {
+				return templateWord{}, 0, errors.New("range must end an argument")
+			}
+
+			body, err := parseTemplateWords(text[first:start])
+			if err != nil {
+				return templateWord{}, 0, err
+			}
+
+			items, err := newWordTemplate("{{range " + pipeline + "}}{{collect .}}{{end}}")
+			if err != nil {
+				return templateWord{}, 0, err
+			}
+
+			return templateWord{items: items, body: body}, end, nil
+		}
+
+		i = end
+	}
+
+	return templateWord{}, 0, errors.New("range without end")
+}
+
+// blockDepth returns 1 for actions opening a block, -1 for end actions and 0 otherwise
+func blockDepth(action string) int {
+	fields := strings.Fields(strings.Trim(action, "- "))
+	if len(fields) == 0 {
+		return 0
+	}
+
+	switch fields[0] {
+	case "range", "if", "with", "block":
+		return 1
+	case "end":
+		return -1
+	}
+
+	return 0
+}
+
+// isTemplateComment reports whether action is a comment
+func isTemplateComment(action string) bool {
+	return strings.HasPrefix(strings.Trim(action, "- "), "/*")
+}
+
+// rangeAction returns the pipeline of a range action at the start of text
+func rangeAction(text string) (string, bool) {
+	if !strings.HasPrefix(text, "{{") {
+		return "", false
+	}
+
+	end := strings.Index(text, "}}")
+	if end < 0 {
+		return "", false
+	}
+
+	action := strings.TrimSpace(strings.Trim(text[2:end], "-"))
+	pipeline, ok := strings.CutPrefix(action, "range ")
+
+	return strings.TrimSpace(pipeline), ok
+}
+
+// literalTemplateText escapes text so templates render it as is
+func literalTemplateText(text string) string {
+	if !strings.Contains(text, "{{") && text != "{" {
+		return text
+	}
+
+	return "{{" + strconv.Quote(text) + "}}"
+}
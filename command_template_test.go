@@ -0,0 +1,119 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCmdTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     any
+		expected []string
+	}{
+		{
+			name:     "placeholders are single arguments",
+			template: "git log --author={{.Author}} {{.Range}}",
+			data:     map[string]any{"Author": "Jane Doe; rm -rf /", "Range": "main..feature && reboot"},
+			expected: []string{"git", "log", "--author=Jane Doe; rm -rf /", "main..feature && reboot"},
+		},
+		{
+			name:     "struct data",
+			template: "echo {{.Name}}",
+			data:     struct{ Name string }{"$(whoami) `id`"},
+			expected: []string{"echo", "$(whoami) `id`"},
+		},
+		{
+			name:     "range expands to multiple arguments",
+			template: "git add -- {{range .Files}}{{.}}{{end}}",
+			data:     map[string]any{"Files": []string{"a.go", "my file.go", "-rf"}},
+			expected: []string{"git", "add", "--", "a.go", "my file.go", "-rf"},
+		},
+		{
+			name:     "range body with several arguments",
+			template: "grep {{range .Patterns}}-e {{.}}{{end}} file",
+			data:     map[string]any{"Patterns": []string{"foo bar", "baz"}},
+			expected: []string{"grep", "-e", "foo bar", "-e", "baz", "file"},
+		},
+		{
+			name:     "empty range",
+			template: "ls {{range .Files}}{{.}}{{end}}",
+			data:     map[string]any{"Files": []string{}},
+			expected: []string{"ls"},
+		},
+		{
+			name:     "empty if blocks arguments are dropped",
+			template: "rm {{if .Force}}-f{{end}} {{.File}}",
+			data:     map[string]any{"Force": false, "File": "a.txt"},
+			expected: []string{"rm", "a.txt"},
+		},
+		{
+			name:     "empty placeholders are empty arguments",
+			template: "grep {{.Pattern}} {{.File}}",
+			data:     map[string]any{"Pattern": "", "File": "a.txt"},
+			expected: []string{"grep", "", "a.txt"},
+		},
+		{
+			name:     "empty range items are empty arguments",
+			template: "touch {{range .Files}}{{.}}{{end}}",
+			data:     map[string]any{"Files": []string{"a", "", "b"}},
+			expected: []string{"touch", "a", "", "b"},
+		},
+		{
+			name:     "quotes",
+			template: `printf '%s {{.}}' "hello {{.Name}}" "" "a \"b\""`,
+			data:     map[string]any{"Name": "world"},
+			expected: []string{"printf", "%s {{.}}", "hello world", "", `a "b"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := CmdTemplate(tt.template)
+			require.NoError(t, err)
+
+			cmd, err := tmpl.Cmd(tt.data)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, append([]string{cmd.cmd}, cmd.args...))
+		})
+	}
+
+	t.Run("executes", func(t *testing.T) {
+		tmpl, err := CmdTemplate("echo {{.Message}}")
+		require.NoError(t, err)
+
+		cmd, err := tmpl.Cmd(map[string]string{"Message": "hello; echo injected"})
+		require.NoError(t, err)
+		require.Equal(t, "hello; echo injected\n", cmd.Stdout())
+	})
+
+	t.Run("parse errors", func(t *testing.T) {
+		for _, template := range []string{
+			"echo {{.Name",
+			"echo 'unterminated",
+			`echo "unterminated`,
+			"echo {{range .Files}}{{.}}",
+			"echo x{{range .Files}}{{.}}{{end}}",
+			"echo {{range .Files}}{{.}}{{end}}x",
+		} {
+			_, err := CmdTemplate(template)
+			require.Error(t, err, template)
+		}
+	})
+
+	t.Run("execution errors", func(t *testing.T) {
+		tmpl, err := CmdTemplate("echo {{.Missing}}")
+		require.NoError(t, err)
+
+		_, err = tmpl.Cmd(map[string]string{})
+		require.ErrorContains(t, err, "Missing")
+
+		tmpl, err = CmdTemplate("{{.Empty}}")
+		require.NoError(t, err)
+
+		_, err = tmpl.Cmd(map[string]string{"Empty": ""})
+		require.ErrorContains(t, err, "no command")
+	})
+}
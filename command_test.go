@@ -231,6 +231,14 @@ func TestCommand_Idempotent(t *testing.T) {
 		require.Equal(t, stdout1, stdout2)
 		require.Equal(t, stdout2, stdout3)
 	})
+
+	t.Run("output strings don't change with the output bytes", func(t *testing.T) {
+		cmd := Cmd("echo", "hello").Run()
+		stdout := cmd.Stdout()
+
+		cmd.StdoutBytes()[0] = 'J'
+		require.Equal(t, "hello\n", stdout)
+	})
 }
 
 func TestCommand_ErrorPropagation(t *testing.T) {
@@ -1697,3 +1705,538 @@ func TestCommandWithDeadline_LongOutput(t *testing.T) {
 		t.Errorf("command took too long: %v (expected ~500ms)", elapsed)
 	}
 }
+
+func TestCommand_AndOrThen(t *testing.T) {
+	tests := []struct {
+		name           string
+		cmd            *Command
+		expectedString string
+		expectedStdout string
+		expectedRan    []string
+		expectedExit   int
+		expectErr      bool
+	}{
+		{
+			name:           "and runs next on success",
+			cmd:            Cmd("echo", "one").And(Cmd("echo", "two")),
+			expectedString: "echo one && echo two",
+			expectedStdout: "one\ntwo\n",
+			expectedRan:    []string{"echo one", "echo two"},
+		},
+		{
+			name:           "and skips next on failure",
+			cmd:            Cmd("false").And(Cmd("echo", "two")),
+			expectedString: "false && echo two",
+			expectedRan:    []string{"false"},
+			expectedExit:   1,
+			expectErr:      true,
+		},
+		{
+			name:           "or runs next on failure",
+			cmd:            Cmd("false").Or(Cmd("echo", "fallback")),
+			expectedString: "false || echo fallback",
+			expectedStdout: "fallback\n",
+			expectedRan:    []string{"false", "echo fallback"},
+		},
+		{
+			name:           "or skips next on success",
+			cmd:            Cmd("echo", "one").Or(Cmd("echo", "fallback")),
+			expectedString: "echo one || echo fallback",
+			expectedStdout: "one\n",
+			expectedRan:    []string{"echo one"},
+		},
+		{
+			name:           "then runs next regardless of failure",
+			cmd:            Cmd("sh", "-c", "exit 3").Then(Cmd("echo", "two")),
+			expectedString: "sh -c exit 3; echo two",
+			expectedStdout: "two\n",
+			expectedRan:    []string{"sh -c exit 3", "echo two"},
+		},
+		{
+			name:           "then keeps last exit code",
+			cmd:            Cmd("echo", "one").Then(Cmd("sh", "-c", "exit 3")),
+			expectedString: "echo one; sh -c exit 3",
+			expectedStdout: "one\n",
+			expectedRan:    []string{"echo one", "sh -c exit 3"},
+			expectedExit:   3,
+			expectErr:      true,
+		},
+		{
+			name:           "build && test || notify when test fails",
+			cmd:            Cmd("echo", "build").And(Cmd("false")).Or(Cmd("echo", "notify")),
+			expectedString: "echo build && false || echo notify",
+			expectedStdout: "build\nnotify\n",
+			expectedRan:    []string{"echo build", "false", "echo notify"},
+		},
+		{
+			name:           "nested right sequence is grouped",
+			cmd:            Cmd("false").And(Cmd("echo", "a").Or(Cmd("echo", "b"))),
+			expectedString: "false && (echo a || echo b)",
+			expectedRan:    []string{"false"},
+			expectedExit:   1,
+			expectErr:      true,
+		},
+		{
+			name:           "then on the left of and is grouped",
+			cmd:            Cmd("echo", "a").Then(Cmd("false")).And(Cmd("echo", "b")),
+			expectedString: "(echo a; false) && echo b",
+			expectedStdout: "a\n",
+			expectedRan:    []string{"echo a", "false"},
+			expectedExit:   1,
+			expectErr:      true,
+		},
+		{
+			name:           "pipelines as operands",
+			cmd:            Cmd("echo", "hello").Pipe("grep", "missing").Or(Cmd("echo", "none")),
+			expectedString: "grep missing || echo none",
+			expectedStdout: "none\n",
+			expectedRan:    []string{"grep missing", "echo none"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedString, tt.cmd.String())
+			require.Equal(t, tt.expectedStdout, tt.cmd.Stdout())
+			require.Equal(t, tt.expectedExit, tt.cmd.ExitCode())
+			if tt.expectErr {
+				require.Error(t, tt.cmd.Error())
+			} else {
+				require.NoError(t, tt.cmd.Error())
+			}
+
+			var ran []string
+			for _, c := range tt.cmd.Ran() {
+				ran = append(ran, c.String())
+			}
+			require.Equal(t, tt.expectedRan, ran)
+		})
+	}
+
+	t.Run("executes lazily", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "ran")
+		cmd := Cmd("touch", file).And(Cmd("echo", "done"))
+
+		_, err := os.Stat(file)
+		require.True(t, os.IsNotExist(err))
+
+		require.Equal(t, "done\n", cmd.Stdout())
+		_, err = os.Stat(file)
+		require.NoError(t, err)
+	})
+
+	t.Run("branches keep their own output", func(t *testing.T) {
+		first := Cmd("sh", "-c", "echo out; echo err >&2; exit 2")
+		second := Cmd("echo", "second")
+		cmd := first.Then(second)
+
+		require.Equal(t, "out\nsecond\n", cmd.Stdout())
+		require.Equal(t, "err\n", cmd.Stderr())
+
+		ran := cmd.Ran()
+		require.Len(t, ran, 2)
+		require.Same(t, first, ran[0])
+		require.Equal(t, "out\n", ran[0].Stdout())
+		require.Equal(t, 2, ran[0].ExitCode())
+		require.Same(t, second, ran[1])
+		require.Equal(t, "second\n", ran[1].Stdout())
+	})
+
+	t.Run("retries run both sides again", func(t *testing.T) {
+		runs := filepath.Join(t.TempDir(), "runs")
+		// the left side succeeds on its third run
+		left := Cmd("sh", "-c", `echo run >> "$0"; [ "$(wc -l < "$0")" -ge 3 ]`, runs)
+		cmd := left.And(Cmd("echo", "done")).Retry(2)
+
+		require.NoError(t, cmd.Error())
+		require.Equal(t, "done\n", cmd.Stdout())
+		require.Len(t, cmd.Ran(), 2)
+
+		content, err := os.ReadFile(runs)
+		require.NoError(t, err)
+		require.Equal(t, "run\nrun\nrun\n", string(content))
+	})
+
+	t.Run("sequence can be piped", func(t *testing.T) {
+		cmd := Cmd("echo", "a").Then(Cmd("echo", "b")).Pipe("wc", "-l")
+		require.Equal(t, "2", cmd.StdoutTrimmed())
+	})
+
+	t.Run("ran on a plain command returns itself", func(t *testing.T) {
+		cmd := Cmd("echo", "hello")
+		require.Equal(t, []*Command{cmd}, cmd.Ran())
+	})
+}
+
+func TestCommand_PipeStderr(t *testing.T) {
+	noisy := "echo out1; echo err1 >&2; echo out2; echo err2 >&2"
+
+	t.Run("pipes stderr and captures stdout", func(t *testing.T) {
+		source := Cmd("sh", "-c", noisy)
+		cmd := source.PipeStderr("grep", "err")
+
+		require.Equal(t, "err1\nerr2\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+		require.Equal(t, "out1\nout2\n", source.Stdout())
+		require.Equal(t, "err1\nerr2\n", source.Stderr())
+	})
+
+	t.Run("stdout is not piped", func(t *testing.T) {
+		cmd := Cmd("echo", "hello").PipeStderr("wc", "-c")
+		require.Equal(t, "0", cmd.StdoutTrimmed())
+	})
+
+	t.Run("from an executed command", func(t *testing.T) {
+		source := Cmd("sh", "-c", noisy).Run()
+		cmd := source.PipeStderr("cat")
+		require.Equal(t, "err1\nerr2\n", cmd.Stdout())
+	})
+
+	t.Run("into a function", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", noisy).PipeStderr("cat").PipeFn(func(stdin string) (string, string, error) {
+			return strings.ToUpper(stdin), "", nil
+		})
+		require.Equal(t, "ERR1\nERR2\n", cmd.Stdout())
+	})
+
+	t.Run("from a function", func(t *testing.T) {
+		cmd := CmdFn(func(string) (string, string, error) {
+			return "out", "err", nil
+		}).PipeStderr("cat")
+		require.Equal(t, "err", cmd.Stdout())
+	})
+
+	t.Run("in the middle of a pipeline", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", noisy).PipeStderr("grep", "2").Pipe("tr", "a-z", "A-Z")
+		require.Equal(t, "ERR2\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("previous error propagates", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "echo failure >&2; exit 1").PipeStderr("cat")
+		require.Error(t, cmd.Error())
+	})
+
+	failing := "echo 'x.c:1: error: boom' >&2; exit 1"
+
+	t.Run("from a failed executed command", func(t *testing.T) {
+		source := Cmd("sh", "-c", failing).Run()
+		cmd := source.PipeStderr("grep", "error")
+		require.Equal(t, "x.c:1: error: boom\n", cmd.Stdout())
+		require.EqualError(t, cmd.Error(), "exit status 1")
+	})
+
+	t.Run("from a failed sequence", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", failing).And(Cmd("echo", "skipped")).PipeStderr("grep", "error")
+		require.Equal(t, "x.c:1: error: boom\n", cmd.Stdout())
+		require.EqualError(t, cmd.Error(), "exit status 1")
+	})
+
+	t.Run("from a failed cached command", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", failing).Cache(&CacheStore{Dir: t.TempDir()}, CacheKey{}).PipeStderr("grep", "error")
+		require.Equal(t, "x.c:1: error: boom\n", cmd.Stdout())
+		require.EqualError(t, cmd.Error(), "exit status 1")
+	})
+
+	t.Run("from a command that couldn't start", func(t *testing.T) {
+		source := Cmd("nonexistent-command-xyz").Run()
+		cmd := source.PipeStderr("echo", "ran")
+		require.Empty(t, cmd.Stdout())
+		require.ErrorIs(t, cmd.Error(), exec.ErrNotFound)
+	})
+
+	t.Run("pipe from a failed executed command doesn't run", func(t *testing.T) {
+		source := Cmd("sh", "-c", failing).Run()
+		cmd := source.Pipe("echo", "ran")
+		require.Empty(t, cmd.Stdout())
+		require.EqualError(t, cmd.Error(), "exit status 1")
+	})
+}
+
+func TestCommand_PipeBoth(t *testing.T) {
+	t.Run("pipes stdout and stderr merged", func(t *testing.T) {
+		source := Cmd("sh", "-c", "echo out; sleep 0.05; echo err >&2; sleep 0.05; echo out2")
+		cmd := source.PipeBoth("cat")
+
+		require.Equal(t, "out\nerr\nout2\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+		require.Equal(t, "out\nout2\n", source.Stdout())
+		require.Equal(t, "err\n", source.Stderr())
+	})
+
+	t.Run("large output on both streams", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "seq 1 20000; seq 1 20000 >&2").PipeBoth("wc", "-l")
+		require.Equal(t, "40000", cmd.StdoutTrimmed())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("from an executed command", func(t *testing.T) {
+		source := Cmd("sh", "-c", "echo out; echo err >&2").Run()
+		require.Equal(t, "out\nerr\n", source.PipeBoth("cat").Stdout())
+	})
+}
+
+// redirectTerminal replaces os.Stdin, os.Stdout and os.Stderr with pipes for the duration of the test.
+// It returns a writer to the fake stdin and a function returning what was written to stdout and stderr.
+func redirectTerminal(t *testing.T) (stdin *os.File, output func() (string, string)) {
+	t.Helper()
+	origStdin, origStdout, origStderr := os.Stdin, os.Stdout, os.Stderr
+
+	inR, inW, err := os.Pipe()
+	require.NoError(t, err)
+	outR, outW, err := os.Pipe()
+	require.NoError(t, err)
+	errR, errW, err := os.Pipe()
+	require.NoError(t, err)
+
+	os.Stdin, os.Stdout, os.Stderr = inR, outW, errW
+	t.Cleanup(func() {
+		os.Stdin, os.Stdout, os.Stderr = origStdin, origStdout, origStderr
+		inW.Close()
+	})
+
+	return inW, func() (string, string) {
+		os.Stdout, os.Stderr = origStdout, origStderr
+		outW.Close()
+		errW.Close()
+		out, _ := io.ReadAll(outR)
+		errOut, _ := io.ReadAll(errR)
+		return string(out), string(errOut)
+	}
+}
+
+func TestCommand_InteractiveCapture(t *testing.T) {
+	t.Run("shows and captures output", func(t *testing.T) {
+		_, terminal := redirectTerminal(t)
+
+		cmd := Cmd("sh", "-c", "echo out; echo err >&2").InteractiveCapture()
+		require.NoError(t, cmd.Error())
+
+		shownOut, shownErr := terminal()
+		require.Equal(t, "out\n", shownOut)
+		require.Equal(t, "err\n", shownErr)
+		require.Equal(t, "out\n", cmd.Stdout())
+		require.Equal(t, "err\n", cmd.Stderr())
+	})
+
+	t.Run("passes terminal input through", func(t *testing.T) {
+		stdin, terminal := redirectTerminal(t)
+		_, err := stdin.WriteString("hello\n")
+		require.NoError(t, err)
+
+		cmd := Cmd("head", "-n", "1").InteractiveCapture()
+		require.NoError(t, cmd.Error())
+
+		shownOut, _ := terminal()
+		require.Equal(t, "hello\n", shownOut)
+		require.Equal(t, "hello\n", cmd.Stdout())
+		require.Empty(t, cmd.RecordedStdin())
+	})
+
+	t.Run("records terminal input", func(t *testing.T) {
+		stdin, terminal := redirectTerminal(t)
+		_, err := stdin.WriteString("1+2\n")
+		require.NoError(t, err)
+
+		cmd := Cmd("head", "-n", "1").InteractiveCapture().RecordStdin()
+		require.NoError(t, cmd.Error())
+		terminal()
+
+		require.Equal(t, "1+2\n", cmd.Stdout())
+		require.Equal(t, "1+2\n", cmd.RecordedStdin())
+	})
+
+	t.Run("records input without capturing output", func(t *testing.T) {
+		stdin, terminal := redirectTerminal(t)
+		_, err := stdin.WriteString("typed\n")
+		require.NoError(t, err)
+
+		cmd := Cmd("head", "-n", "1").Interactive().RecordStdin()
+		require.NoError(t, cmd.Error())
+
+		shownOut, _ := terminal()
+		require.Equal(t, "typed\n", shownOut)
+		require.Empty(t, cmd.Stdout())
+		require.Equal(t, "typed\n", cmd.RecordedStdin())
+	})
+
+	t.Run("recording stops when the command exits", func(t *testing.T) {
+		stdin, terminal := redirectTerminal(t)
+		_, err := stdin.WriteString("typed\n")
+		require.NoError(t, err)
+
+		cmd := Cmd("head", "-n", "1").InteractiveCapture().RecordStdin()
+		require.NoError(t, cmd.Error())
+		terminal()
+
+		_, err = stdin.WriteString("after\n")
+		require.NoError(t, err)
+		require.NoError(t, os.Stdin.SetReadDeadline(time.Now().Add(time.Second)))
+		next := make([]byte, 16)
+		n, err := os.Stdin.Read(next)
+		require.NoError(t, err)
+		require.Equal(t, "after\n", string(next[:n]))
+		require.Equal(t, "typed\n", cmd.RecordedStdin())
+	})
+
+	t.Run("input is not recorded", func(t *testing.T) {
+		_, terminal := redirectTerminal(t)
+
+		cmd := Cmd("cat").Input("from input").InteractiveCapture().RecordStdin()
+		require.NoError(t, cmd.Error())
+		terminal()
+
+		require.Equal(t, "from input", cmd.Stdout())
+		require.Empty(t, cmd.RecordedStdin())
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		_, terminal := redirectTerminal(t)
+
+		cmd := Cmd("sh", "-c", "echo failing; exit 3").InteractiveCapture()
+		require.Error(t, cmd.Error())
+		terminal()
+
+		require.Equal(t, 3, cmd.ExitCode())
+		require.Equal(t, "failing\n", cmd.Stdout())
+	})
+}
+
+func TestCommand_Bytes(t *testing.T) {
+	binary := []byte{0x00, 0xff, 0xfe, 0x80, '\n', 0x00, 0x7f}
+
+	t.Run("binary input and output round trip", func(t *testing.T) {
+		cmd := Cmd("cat").InputBytes(binary)
+		require.Equal(t, binary, cmd.StdoutBytes())
+		require.Equal(t, string(binary), cmd.Stdout())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("binary through a pipeline", func(t *testing.T) {
+		cmd := Cmd("cat").InputBytes(binary).Pipe("gzip").Pipe("gzip", "-d")
+		require.Equal(t, binary, cmd.StdoutBytes())
+	})
+
+	t.Run("stderr bytes", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "printf 'a\\000b' >&2")
+		require.Equal(t, []byte("a\x00b"), cmd.StderrBytes())
+	})
+
+	t.Run("function with bytes", func(t *testing.T) {
+		cmd := CmdFnBytes(func(stdin []byte) ([]byte, []byte, error) {
+			out := make([]byte, len(stdin))
+			for i, b := range stdin {
+				out[i] = ^b
+			}
+			return out, []byte{0xff}, nil
+		}).InputBytes(binary)
+
+		expected := make([]byte, len(binary))
+		for i, b := range binary {
+			expected[i] = ^b
+		}
+		require.Equal(t, expected, cmd.StdoutBytes())
+		require.Equal(t, []byte{0xff}, cmd.StderrBytes())
+	})
+
+	t.Run("pipe to a function with bytes", func(t *testing.T) {
+		cmd := Cmd("cat").InputBytes(binary).PipeFnBytes(func(stdin []byte) ([]byte, []byte, error) {
+			return []byte(fmt.Sprint(len(stdin))), nil, nil
+		})
+		require.Equal(t, "7", cmd.Stdout())
+	})
+
+	t.Run("function error", func(t *testing.T) {
+		cmd := CmdFnBytes(func([]byte) ([]byte, []byte, error) {
+			return nil, nil, errors.New("failed")
+		})
+		require.EqualError(t, cmd.Error(), "failed")
+		require.Empty(t, cmd.StdoutBytes())
+	})
+}
+
+// BenchmarkCommand_LargeOutput compares reading a 100 MB output as a string and as bytes.
+// Run with: go test -run none -bench LargeOutput -benchmem
+func BenchmarkCommand_LargeOutput(b *testing.B) {
+	const size = "104857600"
+
+	b.Run("Stdout", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_ = Cmd("head", "-c", size, "/dev/zero").Stdout()
+		}
+	})
+
+	b.Run("StdoutBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_ = Cmd("head", "-c", size, "/dev/zero").StdoutBytes()
+		}
+	})
+
+	b.Run("PipeFn", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_ = Cmd("head", "-c", size, "/dev/zero").PipeFn(func(stdin string) (string, string, error) {
+				return stdin, "", nil
+			}).StdoutBytes()
+		}
+	})
+
+	b.Run("PipeFnBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_ = Cmd("head", "-c", size, "/dev/zero").PipeFnBytes(func(stdin []byte) ([]byte, []byte, error) {
+				return stdin, nil, nil
+			}).StdoutBytes()
+		}
+	})
+}
+
+func TestCommand_IdleTimeout(t *testing.T) {
+	t.Run("kills silent command", func(t *testing.T) {
+		start := time.Now()
+		cmd := Cmd("sleep", "5").IdleTimeout(200 * time.Millisecond)
+
+		err := cmd.Error()
+		require.ErrorIs(t, err, ErrIdleTimeout)
+		require.Contains(t, err.Error(), "idle timeout: no output for 200ms")
+		require.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("output keeps command alive", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "for i in 1 2 3 4 5; do echo $i; sleep 0.1; done").IdleTimeout(300 * time.Millisecond)
+		require.NoError(t, cmd.Error())
+		require.Equal(t, "1\n2\n3\n4\n5\n", cmd.Stdout())
+	})
+
+	t.Run("stderr output keeps command alive", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "for i in 1 2 3 4 5; do echo $i >&2; sleep 0.1; done").IdleTimeout(300 * time.Millisecond)
+		require.NoError(t, cmd.Error())
+		require.Equal(t, "1\n2\n3\n4\n5\n", cmd.Stderr())
+	})
+
+	t.Run("kills command that stops writing", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "echo started; exec sleep 5").IdleTimeout(200 * time.Millisecond)
+		require.ErrorIs(t, cmd.Error(), ErrIdleTimeout)
+		require.Equal(t, "started\n", cmd.Stdout())
+	})
+
+	t.Run("distinguishable from total timeout", func(t *testing.T) {
+		cmd := Cmd("sleep", "5").IdleTimeout(5 * time.Second).WithTimeout(200 * time.Millisecond)
+		err := cmd.Error()
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrIdleTimeout)
+	})
+
+	t.Run("in a streaming pipeline", func(t *testing.T) {
+		cmd := Cmd("sh", "-c", "echo data; exec sleep 5").IdleTimeout(200 * time.Millisecond).Pipe("cat")
+		require.Error(t, cmd.Error())
+		require.Equal(t, "data\n", cmd.Stdout())
+	})
+
+	t.Run("no timeout when command finishes", func(t *testing.T) {
+		cmd := Cmd("true").IdleTimeout(100 * time.Millisecond)
+		require.NoError(t, cmd.Error())
+	})
+}
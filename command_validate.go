@@ -0,0 +1,248 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// requirement is a version constraint on an executable checked by Validate
+type requirement struct {
+	cmd        string
+	constraint string
+}
+
+// Requires adds a version constraint on an executable, checked by Validate.
+// The executable runs with the same working directory and environment as this command.
+// See the Requires function for the constraint syntax.
+//
+// Example:
+//
+//	err := types.Cmd("git", "switch", "main").Requires("git", ">= 2.23").Validate()
+func (c *Command) Requires(cmd, constraint string) *Command {
+	c.requirements = append(c.requirements, requirement{cmd: cmd, constraint: constraint})
+	return c
+}
+
+// Validate checks, without running anything, that every executable in the command
+// chain can be found. Executables are resolved the same way they are executed:
+// relative paths against Dir, names against the PATH set with Env or the current PATH.
+// Sudo commands also require sudo to be found. Version constraints added with
+// Requires are checked by running the executables with --version.
+//
+// All problems are reported at once in the returned error, one per line.
+// Commands created by Xargs and ForEachLine templates can't be validated in advance.
+//
+// Example:
+//
+//	pipeline := types.Cmd("jq", ".items[]").Input(data).Pipe("yq", "-P")
+//	if err := pipeline.Validate(); err != nil {
+//		log.Fatal(err) // jq: exec: "jq": executable file not found in $PATH
+//	}
+//	output := pipeline.Stdout()
+func (c *Command) Validate() error {
+	var errs []error
+	seen := map[*Command]bool{}
+
+	var visit func(cmd *Command)
+	visit = func(cmd *Command) {
+		if cmd == nil || seen[cmd] {
+			return
+		}
+		seen[cmd] = true
+
+		visit(cmd.previous)
+		visit(cmd.seqLeft)
+		visit(cmd.seqRight)
+		for _, branch := range cmd.teeBranches {
+			visit(branch)
+		}
+
+		if cmd.cmd != "" {
+			if cmd.useSudo {
+				if _, err := cmd.lookPath("sudo"); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", cmd, err))
+				}
+			}
+
+			if _, err := cmd.lookPath(cmd.cmd); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", cmd.cmd, err))
+			}
+		}
+
+		for _, req := range cmd.requirements {
+			version := Cmd(req.cmd, "--version").Dir(cmd.dir).EnvMap(cmd.env)
+			version.clearEnv = cmd.clearEnv
+			version.inheritEnv, version.inheritEnvPrefix, version.envFiles = cmd.inheritEnv, cmd.inheritEnvPrefix, cmd.envFiles
+			if err := checkVersion(version, req.constraint); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", req.cmd, err))
+			}
+		}
+	}
+	visit(c)
+
+	return errors.Join(errs...)
+}
+
+// pathEnv returns the PATH used to find executables: the one of the effective environment, or the current one
+func (c *Command) pathEnv() string {
+	if env, err := c.environ(); err == nil {
+		if path, ok := env["PATH"]; ok {
+			return path
+		}
+	}
+
+	return os.Getenv("PATH")
+}
+
+// lookPath resolves name like exec.LookPath, but relative to the command's Dir and PATH
+func (c *Command) lookPath(name string) (string, error) {
+	if strings.Contains(name, string(filepath.Separator)) {
+		path := name
+		if !filepath.IsAbs(path) && c.dir != "" {
+			path = filepath.Join(c.dir, path)
+		}
+
+		if err := isExecutable(path); err != nil {
+			return "", fmt.Errorf("exec: %q: %w", name, err)
+		}
+
+		return path, nil
+	}
+
+	for _, dir := range filepath.SplitList(c.pathEnv()) {
+		if dir == "" {
+			dir = "."
+		}
+		if !filepath.IsAbs(dir) && c.dir != "" {
+			dir = filepath.Join(c.dir, dir)
+		}
+
+		path := filepath.Join(dir, name)
+		if isExecutable(path) == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("exec: %q: executable file not found in $PATH", name)
+}
+
+func isExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return os.ErrPermission
+	}
+
+	return nil
+}
+
+// Requires runs cmd with --version and checks the first version number in its output
+// against constraint. A constraint is a comma separated list of comparisons, each is an
+// operator (>=, >, <=, <, =, ==, !=) followed by a version, no operator means =.
+// Versions are compared numerically, missing components count as zero.
+//
+// Example:
+//
+//	if err := types.Requires("git", ">= 2.30, < 3"); err != nil {
+//		log.Fatal(err) // git: version 2.25.1 doesn't satisfy >= 2.30
+//	}
+func Requires(cmd, constraint string) error {
+	if err := checkVersion(Cmd(cmd, "--version"), constraint); err != nil {
+		return fmt.Errorf("%s: %w", cmd, err)
+	}
+
+	return nil
+}
+
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)+|\d+`)
+
+// checkVersion runs the version command and checks its output against constraint
+func checkVersion(cmd *Command, constraint string) error {
+	if err := cmd.Error(); err != nil {
+		return err
+	}
+
+	found := versionPattern.FindString(cmd.StdoutStderr())
+	if found == "" {
+		return fmt.Errorf("can't find a version in %q output", cmd)
+	}
+
+	version := parseVersion(found)
+	for _, part := range strings.Split(constraint, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		op := strings.TrimRight(part, "0123456789. ")
+		want := versionPattern.FindString(part[len(op):])
+		if want == "" {
+			return fmt.Errorf("invalid version constraint %q", part)
+		}
+
+		cmp := compareVersions(version, parseVersion(want))
+		var ok bool
+		switch strings.TrimSpace(op) {
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		case "<=":
+			ok = cmp <= 0
+		case "<":
+			ok = cmp < 0
+		case "", "=", "==":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		default:
+			return fmt.Errorf("invalid version constraint %q", part)
+		}
+
+		if !ok {
+			return fmt.Errorf("version %s doesn't satisfy %s", found, constraint)
+		}
+	}
+
+	return nil
+}
+
+func parseVersion(version string) []int {
+	var parts []int
+	for _, part := range strings.Split(version, ".") {
+		n, _ := strconv.Atoi(part)
+		parts = append(parts, n)
+	}
+
+	return parts
+}
+
+// compareVersions returns -1, 0 or 1 if a is less than, equal to or greater than b
+func compareVersions(a, b []int) int {
+	for i := range max(len(a), len(b)) {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,146 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeScript creates an executable shell script in dir and returns its path
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755))
+	return path
+}
+
+func TestCommand_Validate(t *testing.T) {
+	t.Run("all executables found", func(t *testing.T) {
+		cmd := Cmd("echo", "hello").Pipe("grep", "h").And(Cmd("true")).Tee(Cmd("cat"))
+		require.NoError(t, cmd.Validate())
+	})
+
+	t.Run("reports every missing executable at once", func(t *testing.T) {
+		cmd := Cmd("missing-one-xyz").Pipe("grep", "x").Pipe("missing-two-xyz").Or(Cmd("missing-three-xyz"))
+
+		err := cmd.Validate()
+		require.EqualError(t, err, ""+
+			`missing-one-xyz: exec: "missing-one-xyz": executable file not found in $PATH`+"\n"+
+			`missing-two-xyz: exec: "missing-two-xyz": executable file not found in $PATH`+"\n"+
+			`missing-three-xyz: exec: "missing-three-xyz": executable file not found in $PATH`)
+	})
+
+	t.Run("does not run anything", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "ran")
+		cmd := Cmd("touch", file)
+
+		require.NoError(t, cmd.Validate())
+		_, err := os.Stat(file)
+		require.True(t, os.IsNotExist(err))
+		require.False(t, cmd.executed)
+	})
+
+	t.Run("relative paths are resolved against Dir", func(t *testing.T) {
+		dir := t.TempDir()
+		writeScript(t, dir, "script.sh", "echo script")
+
+		require.NoError(t, Cmd("./script.sh").Dir(dir).Validate())
+		require.Error(t, Cmd("./script.sh").Validate())
+	})
+
+	t.Run("non executable files are reported", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "data"), nil, 0644))
+
+		require.Error(t, Cmd("./data").Dir(dir).Validate())
+	})
+
+	t.Run("names are resolved against Env PATH", func(t *testing.T) {
+		dir := t.TempDir()
+		writeScript(t, dir, "only-in-custom-path", "echo custom")
+
+		require.Error(t, Cmd("only-in-custom-path").Validate())
+
+		cmd := Cmd("only-in-custom-path").Env("PATH", dir)
+		require.NoError(t, cmd.Validate())
+		require.Equal(t, "custom\n", cmd.Stdout())
+	})
+
+	t.Run("function commands are skipped", func(t *testing.T) {
+		cmd := CmdFn(func(string) (string, string, error) { return "", "", nil }).Pipe("cat")
+		require.NoError(t, cmd.Validate())
+	})
+
+	t.Run("checks requirements", func(t *testing.T) {
+		dir := t.TempDir()
+		writeScript(t, dir, "tool", "echo 'tool version 2.31.4 (build abc)'")
+
+		cmd := Cmd("tool").Env("PATH", dir+":"+os.Getenv("PATH"))
+		require.NoError(t, cmd.Requires("tool", ">= 2.30").Validate())
+		require.EqualError(t, cmd.Requires("tool", "< 2.31").Validate(), "tool: version 2.31.4 doesn't satisfy < 2.31")
+	})
+}
+
+func TestRequires(t *testing.T) {
+	dir := t.TempDir()
+	writeScript(t, dir, "tool", "echo 'tool version 2.31.4'")
+	writeScript(t, dir, "stderr-tool", "echo 'openjdk version \"17.0.2\"' >&2")
+	writeScript(t, dir, "noversion", "echo 'no version here'")
+	t.Setenv("PATH", dir+":"+os.Getenv("PATH"))
+
+	tests := []struct {
+		name       string
+		cmd        string
+		constraint string
+		wantErr    string
+	}{
+		{name: "greater or equal", cmd: "tool", constraint: ">= 2.30"},
+		{name: "greater or equal exact", cmd: "tool", constraint: ">=2.31.4"},
+		{name: "greater", cmd: "tool", constraint: "> 2"},
+		{name: "less", cmd: "tool", constraint: "< 3"},
+		{name: "less or equal", cmd: "tool", constraint: "<= 2.31.4"},
+		{name: "range", cmd: "tool", constraint: ">= 2.30, < 3"},
+		{name: "equal with missing components", cmd: "tool", constraint: "= 2.31.4.0"},
+		{name: "no operator means equal", cmd: "tool", constraint: "2.31.4"},
+		{name: "not equal", cmd: "tool", constraint: "!= 2.30"},
+		{name: "numeric comparison", cmd: "tool", constraint: "> 2.4"},
+		{name: "version on stderr", cmd: "stderr-tool", constraint: ">= 11"},
+		{
+			name:       "not satisfied",
+			cmd:        "tool",
+			constraint: ">= 2.30, < 2.31",
+			wantErr:    "tool: version 2.31.4 doesn't satisfy >= 2.30, < 2.31",
+		},
+		{
+			name:       "invalid operator",
+			cmd:        "tool",
+			constraint: "~> 2",
+			wantErr:    `tool: invalid version constraint "~> 2"`,
+		},
+		{
+			name:       "no version in output",
+			cmd:        "noversion",
+			constraint: ">= 1",
+			wantErr:    `noversion: can't find a version in "noversion --version" output`,
+		},
+		{
+			name:       "missing executable",
+			cmd:        "missing-tool-xyz",
+			constraint: ">= 1",
+			wantErr:    `missing-tool-xyz: exec: "missing-tool-xyz": executable file not found in $PATH`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Requires(tt.cmd, tt.constraint)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
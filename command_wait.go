@@ -0,0 +1,117 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// WaitCondition reports whether a finished attempt of WaitUntil satisfies the wait
+type WaitCondition func(c *Command) bool
+
+// UntilSuccess is satisfied when the command exits without error
+func UntilSuccess() WaitCondition {
+	return func(c *Command) bool { return c.Error() == nil }
+}
+
+// UntilStdoutMatches is satisfied when the command stdout matches pattern, whatever its exit code is
+func UntilStdoutMatches(pattern *regexp.Regexp) WaitCondition {
+	return func(c *Command) bool { return pattern.Match(c.StdoutBytes()) }
+}
+
+// WaitOptions controls the attempts of WaitUntilWith
+type WaitOptions struct {
+	// Interval is the delay between the end of an attempt and the start of the next one
+	Interval time.Duration
+	// Timeout stops waiting after this duration, it kills a running attempt unless the
+	// command has its own context. Zero means no timeout
+	Timeout time.Duration
+	// Backoff multiplies the interval after every attempt when greater than 1
+	Backoff float64
+	// MaxInterval caps the interval growth by Backoff. Zero means no limit
+	MaxInterval time.Duration
+}
+
+// WaitUntil runs a fresh copy of cmd every interval until cond is satisfied and returns
+// the attempt that satisfied it. Conditions can be UntilSuccess, UntilStdoutMatches or
+// any function of the finished attempt.
+//
+// When ctx is cancelled the running attempt is killed and the error wraps the context
+// error with the last attempt error and output. Commands that have their own context keep
+// it instead, their running attempt finishes before the wait stops. Use WaitUntilWith for
+// a timeout and backoff.
+//
+// Example:
+//
+//	_, err := types.WaitUntil(ctx,
+//		types.Cmd("kubectl", "rollout", "status", "deployment/web"),
+//		types.UntilStdoutMatches(regexp.MustCompile(`successfully rolled out`)),
+//		5*time.Second,
+//	)
+func WaitUntil(ctx context.Context, cmd *Command, cond WaitCondition, interval time.Duration) (*Command, error) {
+	return WaitUntilWith(ctx, cmd, cond, WaitOptions{Interval: interval})
+}
+
+// WaitUntilWith is like WaitUntil with a timeout and backoff.
+//
+// Example:
+//
+//	attempt, err := types.WaitUntilWith(ctx,
+//		types.Cmd("curl", "-sf", "http://localhost:8080/health"),
+//		types.UntilSuccess(),
+//		types.WaitOptions{Interval: time.Second, Backoff: 2, MaxInterval: 30 * time.Second, Timeout: 5 * time.Minute},
+//	)
+//	if errors.Is(err, context.DeadlineExceeded) {
+//		log.Fatal(err) // includes the last attempt output
+//	}
+func WaitUntilWith(ctx context.Context, cmd *Command, cond WaitCondition, opts WaitOptions) (*Command, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	interval := opts.Interval
+	for attempts := 1; ; attempts++ {
+		attempt := cmd.clone(ctx).Run()
+		if cond(attempt) {
+			return attempt, nil
+		}
+
+		if ctx.Err() != nil {
+			return attempt, waitError(ctx, attempt, attempts)
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, waitError(ctx, attempt, attempts)
+		}
+
+		if opts.Backoff > 1 {
+			interval = time.Duration(float64(interval) * opts.Backoff)
+			if opts.MaxInterval > 0 {
+				interval = min(interval, opts.MaxInterval)
+			}
+		}
+	}
+}
+
+// waitError describes why the wait stopped and the last attempt result
+func waitError(ctx context.Context, attempt *Command, attempts int) error {
+	result := "condition not met"
+	if err := attempt.Error(); err != nil {
+		result = err.Error()
+	}
+
+	err := fmt.Errorf("waiting for %s: %w after %d attempts, last attempt: %s", attempt, ctx.Err(), attempts, result)
+	if output := strings.TrimSpace(attempt.StdoutStderr()); output != "" {
+		err = fmt.Errorf("%w\n%s", err, output)
+	}
+
+	return attempt.redactErr(err)
+}
@@ -0,0 +1,92 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitUntil(t *testing.T) {
+	// counter returns a command failing until its third run
+	counter := func(runs *int) *Command {
+		return CmdFn(func(stdin string) (string, string, error) {
+			*runs++
+			if *runs < 3 {
+				return fmt.Sprintf("waiting %d\n", *runs), "", errors.New("not ready")
+			}
+			return "successfully rolled out\n", "", nil
+		})
+	}
+
+	t.Run("until success", func(t *testing.T) {
+		runs := 0
+		attempt, err := WaitUntil(context.Background(), counter(&runs), UntilSuccess(), time.Millisecond)
+		require.NoError(t, err)
+		require.Equal(t, 3, runs)
+		require.Equal(t, "successfully rolled out\n", attempt.Stdout())
+	})
+
+	t.Run("until stdout matches", func(t *testing.T) {
+		runs := 0
+		_, err := WaitUntil(context.Background(), counter(&runs), UntilStdoutMatches(regexp.MustCompile(`waiting 2`)), time.Millisecond)
+		require.NoError(t, err)
+		require.Equal(t, 2, runs)
+	})
+
+	t.Run("custom condition", func(t *testing.T) {
+		attempt, err := WaitUntil(context.Background(), Cmd("sh", "-c", "exit 3"), func(c *Command) bool {
+			return c.ExitCode() == 3
+		}, time.Millisecond)
+		require.NoError(t, err)
+		require.Equal(t, 3, attempt.ExitCode())
+	})
+
+	t.Run("timeout includes last attempt output", func(t *testing.T) {
+		// the timeout expires while waiting for the next attempt, not during an attempt
+		_, err := WaitUntilWith(context.Background(), Cmd("sh", "-c", "echo pending; exit 1"), UntilSuccess(), WaitOptions{
+			Interval: time.Minute,
+			Timeout:  500 * time.Millisecond,
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, "waiting for sh -c echo pending; exit 1: context deadline exceeded after 1 attempts, last attempt: exit status 1\npending", err.Error())
+	})
+
+	t.Run("timeout kills running attempt", func(t *testing.T) {
+		start := time.Now()
+		_, err := WaitUntilWith(context.Background(), Cmd("sleep", "10"), UntilSuccess(), WaitOptions{Timeout: 50 * time.Millisecond})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := WaitUntil(ctx, CmdFn(func(string) (string, string, error) { return "", "", nil }), func(*Command) bool { return false }, time.Second)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Contains(t, err.Error(), "after 1 attempts, last attempt: condition not met")
+	})
+
+	t.Run("backoff grows the interval up to the max", func(t *testing.T) {
+		var starts []time.Time
+		cmd := CmdFn(func(string) (string, string, error) {
+			starts = append(starts, time.Now())
+			return "", "", nil
+		})
+
+		_, err := WaitUntilWith(context.Background(), cmd, func(*Command) bool { return len(starts) == 4 }, WaitOptions{
+			Interval:    10 * time.Millisecond,
+			Backoff:     3,
+			MaxInterval: 50 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, starts[1].Sub(starts[0]), 10*time.Millisecond)
+		require.GreaterOrEqual(t, starts[2].Sub(starts[1]), 30*time.Millisecond)
+		require.GreaterOrEqual(t, starts[3].Sub(starts[2]), 50*time.Millisecond)
+	})
+}
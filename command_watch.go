@@ -0,0 +1,334 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WatchSnapshot is the result of one run of a watched command
+type WatchSnapshot struct {
+	// Time is when the run started
+	Time time.Time
+	// Command is the fresh copy of the watched command that ran, for other results like Ran
+	Command *Command
+	Stdout  string
+	Stderr  string
+	// ExitCode is the exit code of the run, see Command.ExitCode
+	ExitCode int
+	Err      error
+	// Changed is true when stdout differs from the previous run, it's false for the first run
+	Changed bool
+	// Diff is a unified diff of stdout against the previous run, empty when nothing changed
+	Diff string
+}
+
+// Watch runs cmd repeatedly, like `watch -n` does, and sends a snapshot of every run
+// to the returned channel. The first run starts immediately and each following run
+// starts interval after the previous one finished, so runs never overlap.
+//
+// Every run executes a fresh copy of cmd, which is left unexecuted. When ctx is
+// cancelled the running command is killed and the channel is closed. Commands that
+// have their own context keep it instead, note that WithTimeout and WithDeadline
+// start counting when they are called, not for each run.
+//
+// Input set with Input or InputBytes is passed again on every run, other readers are
+// consumed by the first run.
+//
+// Example:
+//
+//	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+//	defer cancel()
+//	for snapshot := range types.Watch(ctx, types.Cmd("kubectl", "get", "pods"), 5*time.Second) {
+//		if snapshot.Changed {
+//			fmt.Print(snapshot.Diff)
+//		}
+//	}
+func Watch(ctx context.Context, cmd *Command, interval time.Duration) <-chan WatchSnapshot {
+	snapshots := make(chan WatchSnapshot)
+
+	go func() {
+		defer close(snapshots)
+
+		var previous *WatchSnapshot
+		for {
+			run := cmd.clone(ctx)
+			snapshot := WatchSnapshot{Time: time.Now(), Command: run}
+			snapshot.Stdout = run.Stdout()
+			snapshot.Stderr = run.Stderr()
+			snapshot.ExitCode = run.ExitCode()
+			snapshot.Err = run.Error()
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			if previous != nil && previous.Stdout != snapshot.Stdout {
+				snapshot.Changed = true
+				snapshot.Diff = unifiedDiff(previous.Stdout, snapshot.Stdout)
+			}
+			previous = &snapshot
+
+			select {
+			case snapshots <- snapshot:
+			case <-ctx.Done():
+				return
+			}
+
+			timer := time.NewTimer(interval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+
+	return snapshots
+}
+
+// unexecuted returns a copy of the command configuration without the state of its
+// execution, the commands it's made of are shared with c
+func (c *Command) unexecuted() *Command {
+	copied := *c
+
+	copied.executed = false
+	copied.stdout, copied.stderr, copied.err = nil, nil, nil
+	copied.exitCode = 0
+	copied.ran = nil
+	copied.stdin = ""
+	copied.running = nil
+	copied.idle = nil
+	copied.signals = nil
+	copied.timeline = nil
+	copied.started = time.Time{}
+	copied.limitedDownstream = false
+	copied.redactor = nil
+
+	copied.args = slices.Clone(c.args)
+	copied.env = maps.Clone(c.env)
+	copied.requirements = slices.Clone(c.requirements)
+	copied.allowedExitCodes = slices.Clone(c.allowedExitCodes)
+	copied.exitCodeMeanings = maps.Clone(c.exitCodeMeanings)
+	copied.redactEnv = slices.Clone(c.redactEnv)
+	copied.secrets = slices.Clone(c.secrets)
+	copied.inheritEnv = slices.Clone(c.inheritEnv)
+	copied.inheritEnvPrefix = slices.Clone(c.inheritEnvPrefix)
+	copied.envFiles = slices.Clone(c.envFiles)
+
+	if c.script != nil {
+		s := *c.script
+		s.path = ""
+		copied.script = &s
+	}
+
+	return &copied
+}
+
+// clone returns an unexecuted copy of the command and all commands it's made of.
+// ctx is used for the commands that have no context.
+func (c *Command) clone(ctx context.Context) *Command {
+	if c == nil {
+		return nil
+	}
+
+	copied := c.unexecuted()
+	copied.previous = c.previous.clone(ctx)
+	copied.seqLeft = c.seqLeft.clone(ctx)
+	copied.seqRight = c.seqRight.clone(ctx)
+	copied.input = rewind(c.input)
+
+	if copied.ctx == nil {
+		copied.ctx = ctx
+	}
+
+	if c.teeBranches != nil {
+		copied.teeBranches = make([]*Command, len(c.teeBranches))
+		for i, branch := range c.teeBranches {
+			copied.teeBranches[i] = branch.clone(ctx)
+		}
+	}
+
+	return copied
+}
+
+// rewind returns a reader from the start of r if r can be read again, like the readers
+// created by Input and InputBytes, otherwise it returns r
+func rewind(r io.Reader) io.Reader {
+	if sized, ok := r.(interface {
+		io.ReaderAt
+		Size() int64
+	}); ok {
+		return io.NewSectionReader(sized, 0, sized.Size())
+	}
+
+	return r
+}
+
+// diffContext is the number of unchanged lines around changes in unified diffs
+const diffContext = 3
+
+// maxDiffCells is the size of the largest longest common subsequence table diffLines builds
+const maxDiffCells = 1 << 20
+
+// diffLine is a line of a diff, op is ' ', '-' or '+'
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff returns the unified diff of the lines of a and b
+func unifiedDiff(a, b string) string {
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	out.WriteString("--- previous\n+++ current\n")
+
+	// line numbers in a and b at the start of lines[i]
+	aLine, bLine := 1, 1
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			aLine++
+			bLine++
+			i++
+			continue
+		}
+
+		// extend the hunk while changes are separated by less than two contexts
+		start := max(0, i-diffContext)
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].op != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(len(lines), end+diffContext)
+
+		aStart, bStart := aLine-(i-start), bLine-(i-start)
+		var aCount, bCount int
+		for _, line := range lines[start:end] {
+			if line.op != '+' {
+				aCount++
+			}
+			if line.op != '-' {
+				bCount++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, line := range lines[start:end] {
+			out.WriteByte(line.op)
+			out.WriteString(line.text)
+			out.WriteByte('\n')
+		}
+
+		for _, line := range lines[i:end] {
+			if line.op != '+' {
+				aLine++
+			}
+			if line.op != '-' {
+				bLine++
+			}
+		}
+		i = end
+	}
+
+	return out.String()
+}
+
+// hunkRange formats the start and count of a hunk, a start of an empty range is the line before it
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the shortest edit from a to b, based on the longest common subsequence
+// of the lines between their common prefix and suffix. When the changed lines are too
+// many to compare, they're all replaced.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var lines []diffLine
+	for _, line := range a[:prefix] {
+		lines = append(lines, diffLine{' ', line})
+	}
+
+	x, y := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// the table is too large for the outputs, replace all the changed lines instead
+	if len(x)*len(y) > maxDiffCells {
+		for _, line := range x {
+			lines = append(lines, diffLine{'-', line})
+		}
+		for _, line := range y {
+			lines = append(lines, diffLine{'+', line})
+		}
+		x, y = nil, nil
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, diffLine{' ', x[i]})
+			i++
+			j++
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', x[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', y[j]})
+			j++
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{' ', line})
+	}
+
+	return lines
+}
@@ -0,0 +1,137 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	t.Run("runs a fresh copy on every tick and diffs stdout", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		runs := 0
+		cmd := CmdFn(func(stdin string) (string, string, error) {
+			runs++
+			return fmt.Sprintf("%sstatic\nrun %d\n", stdin, min(runs, 2)), "", nil
+		}).Input("input\n")
+
+		snapshots := Watch(ctx, cmd, time.Millisecond)
+
+		first := <-snapshots
+		require.Equal(t, "input\nstatic\nrun 1\n", first.Stdout)
+		require.False(t, first.Changed)
+		require.Empty(t, first.Diff)
+
+		second := <-snapshots
+		require.Equal(t, "input\nstatic\nrun 2\n", second.Stdout)
+		require.True(t, second.Changed)
+		require.Equal(t, "--- previous\n+++ current\n@@ -1,3 +1,3 @@\n input\n static\n-run 1\n+run 2\n", second.Diff)
+		require.True(t, second.Command.executed)
+
+		third := <-snapshots
+		require.False(t, third.Changed)
+		require.Empty(t, third.Diff)
+		require.False(t, cmd.executed)
+	})
+
+	t.Run("reports exit codes and errors of each run", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		snapshot := <-Watch(ctx, Cmd("sh", "-c", "echo out; exit 3"), time.Millisecond)
+		require.Equal(t, "out\n", snapshot.Stdout)
+		require.Equal(t, 3, snapshot.ExitCode)
+		require.Error(t, snapshot.Err)
+	})
+
+	t.Run("redacts secrets", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		token := "watch-secret-token"
+		cmd := Cmd("sh", "-c", "echo $TOKEN; echo $TOKEN >&2; exit 1").Env("TOKEN", token).Secret(token).RedactOutput()
+		snapshot := <-Watch(ctx, cmd, time.Millisecond)
+		require.Equal(t, "***\n", snapshot.Stdout)
+		require.Equal(t, "***\n", snapshot.Stderr)
+		require.NotContains(t, snapshot.Err.Error(), token)
+	})
+
+	t.Run("clones pipelines", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		snapshots := Watch(ctx, Cmd("echo", "hello").Pipe("tr", "a-z", "A-Z"), time.Millisecond)
+		require.Equal(t, "HELLO\n", (<-snapshots).Stdout)
+		require.Equal(t, "HELLO\n", (<-snapshots).Stdout)
+	})
+
+	t.Run("stops and kills the command when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		snapshots := Watch(ctx, Cmd("sleep", "10"), time.Millisecond)
+
+		start := time.Now()
+		cancel()
+		_, ok := <-snapshots
+		require.False(t, ok)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tcs := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{
+			name:     "added to empty",
+			a:        "",
+			b:        "a\nb\n",
+			expected: "--- previous\n+++ current\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:     "removed line",
+			a:        "a\nb\nc\n",
+			b:        "a\nc\n",
+			expected: "--- previous\n+++ current\n@@ -1,3 +1,2 @@\n a\n-b\n c\n",
+		},
+		{
+			name:     "distant changes in separate hunks",
+			a:        "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:        "0\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n13\n",
+			expected: "--- previous\n+++ current\n@@ -1,4 +1,4 @@\n-1\n+0\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+13\n",
+		},
+		{
+			name:     "close changes in one hunk",
+			a:        "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:        "0\n2\n3\n4\n5\n6\n7\n9\n",
+			expected: "--- previous\n+++ current\n@@ -1,8 +1,8 @@\n-1\n+0\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+9\n",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, unifiedDiff(tc.a, tc.b))
+		})
+	}
+
+	t.Run("replaces all lines of large changes", func(t *testing.T) {
+		var a, b []string
+		for i := range 1100 {
+			a = append(a, fmt.Sprint("a", i))
+			b = append(b, fmt.Sprint("b", i))
+		}
+		a[500], b[500] = "common", "common"
+
+		lines := diffLines(a, b)
+		require.Len(t, lines, 2200)
+		require.Equal(t, diffLine{'-', "a0"}, lines[0])
+		require.Equal(t, diffLine{'-', "common"}, lines[500])
+		require.Equal(t, diffLine{'+', "b0"}, lines[1100])
+	})
+}
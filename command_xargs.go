@@ -0,0 +1,224 @@
+package types
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// XargsOptions controls how Xargs and ForEachLine run sub-commands
+type XargsOptions struct {
+	// Parallel is the number of sub-commands running at the same time, defaults to 1
+	Parallel int
+	// Batch is the number of lines passed to each sub-command, defaults to 1. Ignored by ForEachLine.
+	Batch int
+	// Ordered collects sub-commands output in input order instead of completion order
+	Ordered bool
+	// StopOnError stops reading input and starting sub-commands after the first failure
+	StopOnError bool
+}
+
+// xargs holds the configuration of a fan-out command
+type xargs struct {
+	template func(lines []string) *Command
+	// name is the name of the template function given by the caller
+	name string
+	opts XargsOptions
+}
+
+// Xargs creates a Command that reads lines from its Input or InputReader and runs
+// the Command returned by template for each batch of lines, similar to xargs.
+// Blank lines are skipped. A template may return nil to skip a batch.
+//
+// The stdout and stderr of the returned Command are the concatenated outputs of the
+// sub-commands, its error joins all sub-commands errors and Ran returns the sub-commands
+// that ran in the same order as their outputs.
+//
+// Example:
+//
+//	file, _ := os.Open("urls.txt")
+//	err := types.Xargs(func(urls []string) *types.Command {
+//		return types.Cmd("curl", append([]string{"-O"}, urls...)...)
+//	}, types.XargsOptions{Parallel: 4, Batch: 10}).InputReader(file).Error()
+func Xargs(template func(lines []string) *Command, opts XargsOptions) *Command {
+	return &Command{
+		xargs: &xargs{template: template, name: funcName(template), opts: opts},
+	}
+}
+
+// Xargs is like the Xargs function but streams lines from this command's stdout.
+// Lines are processed as they are produced, without waiting for this command to finish.
+//
+// Example:
+//
+//	err := types.Cmd("find", ".", "-name", "*.go").
+//		Xargs(func(files []string) *types.Command {
+//			return types.Cmd("gofmt", append([]string{"-l"}, files...)...)
+//		}, types.XargsOptions{Parallel: 8, Batch: 50}).
+//		Error()
+func (c *Command) Xargs(template func(lines []string) *Command, opts XargsOptions) *Command {
+	next := Xargs(template, opts)
+	next.previous = c
+
+	return next
+}
+
+// ForEachLine is like Xargs with a sub-command for every line.
+//
+// Example:
+//
+//	sizes := types.Cmd("find", ".", "-name", "*.log").
+//		ForEachLine(func(file string) *types.Command {
+//			return types.Cmd("du", "-h", file)
+//		}, types.XargsOptions{Parallel: 4, Ordered: true}).
+//		Stdout()
+func (c *Command) ForEachLine(template func(line string) *Command, opts XargsOptions) *Command {
+	opts.Batch = 1
+
+	next := c.Xargs(func(lines []string) *Command { return template(lines[0]) }, opts)
+	next.xargs.name = funcName(template)
+
+	return next
+}
+
+// executeXargs reads lines in batches and runs a sub-command for each batch
+func (c *Command) executeXargs() {
+	c.ran = c.ran[:0]
+	c.exitCode = 0
+
+	var source io.Reader = strings.NewReader("")
+	if c.previous != nil {
+		// The sub-commands take the limiter slots, the previous command holding one while
+		// they wait for it could block them forever
+		c.previous.limitedDownstream = true
+		pipe, err := c.previous.getPipe(c.pipeFrom)
+		if err != nil {
+			c.err = err
+			return
+		}
+		source = pipe
+	} else if c.input != nil {
+		source = c.input
+	}
+
+	opts := c.xargs.opts
+	batchSize := max(opts.Batch, 1)
+	var failed atomic.Bool
+	stopped := func() bool { return opts.StopOnError && failed.Load() }
+
+	batches := make(chan []string)
+	var scanErr error
+	go func() {
+		defer close(batches)
+
+		scanner := bufio.NewScanner(source)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		var lines []string
+		for scanner.Scan() {
+			if stopped() {
+				// Stop a previous command from producing more lines
+				if closer, ok := source.(io.Closer); ok && c.previous != nil {
+					closer.Close()
+				}
+				return
+			}
+
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+
+			lines = append(lines, scanner.Text())
+			if len(lines) == batchSize {
+				batches <- lines
+				lines = nil
+			}
+		}
+
+		if len(lines) > 0 && !stopped() {
+			batches <- lines
+		}
+
+		if scanErr = scanner.Err(); scanErr != nil {
+			// The rest of the input is never read, a previous command writing it would block
+			if closer, ok := source.(io.Closer); ok && c.previous != nil {
+				closer.Close()
+			}
+		}
+	}()
+
+	run := func(lines []string) *Command {
+		if stopped() {
+			return nil
+		}
+
+		cmd := c.xargs.template(lines)
+		if cmd == nil {
+			return nil
+		}
+		if cmd.audit == nil {
+			cmd.audit = c.audit
+		}
+		if cmd.limit == nil {
+			cmd.limit = c.limit
+		}
+
+		if cmd.Run().err != nil {
+			failed.Store(true)
+		}
+
+		return cmd
+	}
+
+	parallel := max(opts.Parallel, 1)
+	var results <-chan *Command
+	if opts.Ordered {
+		results = OrderedParallelizeChan(batches, parallel, func(in <-chan []string) <-chan *Command {
+			return ChanMap(in, run)
+		})
+	} else {
+		out := make(chan *Command)
+		var wg sync.WaitGroup
+		for range parallel {
+			wg.Go(func() {
+				for lines := range batches {
+					out <- run(lines)
+				}
+			})
+		}
+		go func() {
+			wg.Wait()
+			close(out)
+		}()
+		results = out
+	}
+
+	var stdout, stderr bytes.Buffer
+	var errs []error
+	for cmd := range results {
+		if cmd == nil {
+			continue
+		}
+
+		c.ran = append(c.ran, cmd)
+		stdout.Write(cmd.stdout)
+		stderr.Write(cmd.stderr)
+		if cmd.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cmd, cmd.err))
+			c.exitCode = cmd.exitCode
+		}
+	}
+
+	c.stdout = stdout.Bytes()
+	c.stderr = stderr.Bytes()
+	c.err = errors.Join(append(errs, scanErr)...)
+}
+
+// xargsString renders the fan-out command, e.g. "xargs -P 4 -n 10 <function>"
+func (c *Command) xargsString() string {
+	return fmt.Sprintf("xargs -P %d -n %d <function>", max(c.xargs.opts.Parallel, 1), max(c.xargs.opts.Batch, 1))
+}
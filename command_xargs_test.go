@@ -0,0 +1,174 @@
+package types
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_ForEachLine(t *testing.T) {
+	echo := func(line string) *Command { return Cmd("echo", "item", line) }
+
+	t.Run("runs a command per line", func(t *testing.T) {
+		cmd := Cmd("printf", "a\nb\n\nc\n").ForEachLine(echo, XargsOptions{})
+
+		require.Equal(t, "item a\nitem b\nitem c\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+		require.Len(t, cmd.Ran(), 3)
+		require.Equal(t, "echo item b", cmd.Ran()[1].String())
+	})
+
+	t.Run("ordered parallel output keeps input order", func(t *testing.T) {
+		cmd := Cmd("seq", "1", "8").ForEachLine(func(line string) *Command {
+			// later lines finish first
+			return Cmd("sh", "-c", "sleep 0.0$((9 - "+line+")); echo "+line)
+		}, XargsOptions{Parallel: 8, Ordered: true})
+
+		require.Equal(t, "1\n2\n3\n4\n5\n6\n7\n8\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("unordered parallel output has every line", func(t *testing.T) {
+		cmd := Cmd("seq", "1", "20").ForEachLine(func(line string) *Command {
+			return Cmd("echo", line)
+		}, XargsOptions{Parallel: 4})
+
+		lines := strings.Fields(cmd.Stdout())
+		require.ElementsMatch(t, strings.Fields(Cmd("seq", "1", "20").Stdout()), lines)
+	})
+
+	t.Run("runs in parallel", func(t *testing.T) {
+		dir := t.TempDir()
+		cmd := Cmd("seq", "1", "4").ForEachLine(func(line string) *Command {
+			return Cmd("sh", "-c", barrier("$1", 4), dir, line)
+		}, XargsOptions{Parallel: 4})
+
+		require.NoError(t, cmd.Error())
+	})
+
+	t.Run("collects all errors", func(t *testing.T) {
+		cmd := Cmd("printf", "1\n2\n3\n").ForEachLine(func(line string) *Command {
+			return Cmd("sh", "-c", "exit "+line)
+		}, XargsOptions{Ordered: true})
+
+		err := cmd.Error()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "sh -c exit 2: exit status 2")
+		require.Contains(t, err.Error(), "sh -c exit 3: exit status 3")
+		require.Len(t, cmd.Ran(), 3)
+		require.Equal(t, 3, cmd.ExitCode())
+	})
+
+	t.Run("stop on first error", func(t *testing.T) {
+		var started atomic.Int32
+		cmd := Cmd("seq", "1", "100000").ForEachLine(func(line string) *Command {
+			started.Add(1)
+			if line == "3" {
+				return Cmd("false")
+			}
+			return Cmd("true")
+		}, XargsOptions{StopOnError: true})
+
+		require.Error(t, cmd.Error())
+		require.Len(t, cmd.Ran(), 3)
+		require.Equal(t, int32(3), started.Load())
+	})
+
+	t.Run("retries run every line again", func(t *testing.T) {
+		cmd := Cmd("printf", "1\n2\n").ForEachLine(func(line string) *Command {
+			return Cmd("sh", "-c", "exit "+line)
+		}, XargsOptions{}).Retry(2)
+
+		require.Error(t, cmd.Error())
+		require.Len(t, cmd.Ran(), 2)
+		require.Equal(t, 2, cmd.ExitCode())
+	})
+
+	t.Run("nil template result skips the line", func(t *testing.T) {
+		cmd := Cmd("printf", "keep\nskip\nkeep\n").ForEachLine(func(line string) *Command {
+			if line == "skip" {
+				return nil
+			}
+			return Cmd("echo", line)
+		}, XargsOptions{})
+
+		require.Equal(t, "keep\nkeep\n", cmd.Stdout())
+	})
+
+	t.Run("previous error propagates", func(t *testing.T) {
+		cmd := Cmd("nonexistent-command-xyz").ForEachLine(echo, XargsOptions{})
+		require.Error(t, cmd.Error())
+		require.Empty(t, cmd.Ran())
+	})
+
+	t.Run("lines too long stop the previous command", func(t *testing.T) {
+		done := filepath.Join(t.TempDir(), "done")
+		source := Cmd("sh", "-c", "(head -c 2000000 /dev/zero | tr '\\0' a; echo; seq 1 100000); touch "+done)
+		cmd := source.ForEachLine(func(line string) *Command {
+			return Cmd("echo", line)
+		}, XargsOptions{})
+
+		require.ErrorIs(t, cmd.Error(), bufio.ErrTooLong)
+		require.Empty(t, cmd.Ran())
+		// the previous command stays blocked writing unless its output is closed
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(done)
+			return err == nil
+		}, 10*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("can be piped", func(t *testing.T) {
+		cmd := Cmd("printf", "a\nb\n").ForEachLine(echo, XargsOptions{Ordered: true}).Pipe("wc", "-l")
+		require.Equal(t, "2", cmd.StdoutTrimmed())
+	})
+}
+
+func TestXargs(t *testing.T) {
+	t.Run("batches lines", func(t *testing.T) {
+		cmd := Xargs(func(lines []string) *Command {
+			return Cmd("echo", lines...)
+		}, XargsOptions{Batch: 2, Ordered: true, Parallel: 2}).Input("1\n2\n3\n4\n5\n")
+
+		require.Equal(t, "1 2\n3 4\n5\n", cmd.Stdout())
+		require.NoError(t, cmd.Error())
+		require.Len(t, cmd.Ran(), 3)
+	})
+
+	t.Run("no input", func(t *testing.T) {
+		cmd := Xargs(func(lines []string) *Command {
+			return Cmd("echo", lines...)
+		}, XargsOptions{})
+
+		require.Empty(t, cmd.Stdout())
+		require.NoError(t, cmd.Error())
+		require.Empty(t, cmd.Ran())
+	})
+
+	t.Run("from a command", func(t *testing.T) {
+		cmd := Cmd("seq", "1", "6").Xargs(func(lines []string) *Command {
+			return Cmd("echo", lines...)
+		}, XargsOptions{Batch: 3})
+
+		require.Equal(t, "1 2 3\n4 5 6\n", cmd.Stdout())
+	})
+
+	t.Run("string", func(t *testing.T) {
+		cmd := Cmd("ls").Xargs(nil, XargsOptions{Parallel: 4, Batch: 10})
+		require.Equal(t, "xargs -P 4 -n 10 <function>", cmd.String())
+	})
+}
+
+// barrier returns a shell script creating the file name in the directory $0 then waiting
+// until n files exist there, so n commands running it finish only if they run at the same
+// time. It fails after 10 seconds.
+func barrier(name string, n int) string {
+	return fmt.Sprintf(`touch "$0/%s"; i=0
+while [ "$(ls "$0" | wc -l)" -lt %d ]; do i=$((i + 1)); [ $i -gt 1000 ] && exit 1; sleep 0.01; done`, name, n)
+}
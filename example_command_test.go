@@ -106,3 +106,15 @@ func ExampleCommand_String() {
 	fmt.Println(cmd.String())
 	// Output: git commit -m message
 }
+
+func ExampleCommand_And() {
+	script := Cmd("echo", "build").
+		And(Cmd("false")).
+		Or(Cmd("echo", "notify"))
+
+	fmt.Println(script.String())
+	fmt.Print(script.Stdout())
+	// Output: echo build && false || echo notify
+	// build
+	// notify
+}
@@ -0,0 +1,339 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// TaskStatus describes the outcome of a task after Tasks.Run
+type TaskStatus int
+
+const (
+	// TaskSucceeded means the task ran and returned no error
+	TaskSucceeded TaskStatus = iota
+	// TaskFailed means the task ran and returned an error
+	TaskFailed
+	// TaskSkipped means the task didn't run because one of its dependencies didn't succeed
+	TaskSkipped
+)
+
+// String returns a lowercase name of the status
+func (s TaskStatus) String() string {
+	switch s {
+	case TaskSucceeded:
+		return "ok"
+	case TaskFailed:
+		return "failed"
+	case TaskSkipped:
+		return "skipped"
+	default:
+		return fmt.Sprintf("TaskStatus(%d)", int(s))
+	}
+}
+
+// Task is a named unit of work with dependencies, it wraps either a *Command or a Go function.
+type Task struct {
+	// name identifies the task and is used to declare dependencies
+	name string
+	// deps are the names of tasks that must succeed before this task runs
+	deps []string
+	// cmd is the command to execute, nil for function tasks
+	cmd *Command
+	// fn is the function to execute, nil for command tasks
+	fn func() error
+}
+
+func (t *Task) run() error {
+	if t.cmd != nil {
+		return t.cmd.Error()
+	}
+
+	if t.fn != nil {
+		return t.fn()
+	}
+
+	return nil
+}
+
+// TaskResult holds the outcome of a single task
+type TaskResult struct {
+	Name     string
+	Status   TaskStatus
+	Err      error
+	Start    time.Time
+	Duration time.Duration
+	// Command is the task's command, nil for function tasks
+	Command *Command
+}
+
+// TaskResults holds the outcome of all tasks of a run in the order they finished
+type TaskResults []TaskResult
+
+// Get returns the result of the task with the given name
+func (r TaskResults) Get(name string) (TaskResult, bool) {
+	for _, result := range r {
+		if result.Name == name {
+			return result, true
+		}
+	}
+
+	return TaskResult{}, false
+}
+
+// Error returns all failed tasks errors joined, or nil if no task failed.
+// Skipped tasks don't contribute to the error as their failed dependency already does.
+func (r TaskResults) Error() error {
+	var errs []error
+	for _, result := range r {
+		if result.Status == TaskFailed {
+			errs = append(errs, fmt.Errorf("task %q: %w", result.Name, result.Err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Summary returns a human readable table of every task, its status and how long it took
+func (r TaskResults) Summary() string {
+	width := 0
+	for _, result := range r {
+		width = max(width, len(result.Name))
+	}
+
+	var total time.Duration
+	var b strings.Builder
+	for _, result := range r {
+		total += result.Duration
+		fmt.Fprintf(&b, "%-7s %-*s %10s", result.Status, width, result.Name, result.Duration.Round(time.Millisecond))
+		if result.Err != nil {
+			fmt.Fprintf(&b, "  %s", result.Err)
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "%d tasks, %s total task time\n", len(r), total.Round(time.Millisecond))
+
+	return b.String()
+}
+
+// Tasks is a collection of named tasks with dependencies between them, similar to
+// make or Ruby's Rake. Tasks are executed as a directed acyclic graph: a task runs
+// only after all its dependencies succeeded, and independent tasks run in parallel.
+//
+// Example:
+//
+//	results, err := types.NewTasks().
+//		Cmd("deps", types.Cmd("go", "mod", "download")).
+//		Cmd("build", types.Cmd("go", "build", "./..."), "deps").
+//		Cmd("test", types.Cmd("go", "test", "./..."), "build").
+//		Fn("done", func() error { fmt.Println("done"); return nil }, "test").
+//		Run("done")
+//	fmt.Print(results.Summary())
+type Tasks struct {
+	order    []string
+	tasks    map[string]*Task
+	parallel int
+}
+
+// NewTasks creates an empty Tasks collection.
+// Tasks run in parallel up to runtime.NumCPU() by default, use Parallel to change it.
+func NewTasks() *Tasks {
+	return &Tasks{
+		tasks:    map[string]*Task{},
+		parallel: runtime.NumCPU(),
+	}
+}
+
+// Cmd defines a task that executes cmd after deps succeed.
+// Defining a task with an existing name replaces it.
+func (t *Tasks) Cmd(name string, cmd *Command, deps ...string) *Tasks {
+	t.add(&Task{name: name, deps: deps, cmd: cmd})
+	return t
+}
+
+// Fn defines a task that calls fn after deps succeed.
+// Defining a task with an existing name replaces it.
+func (t *Tasks) Fn(name string, fn func() error, deps ...string) *Tasks {
+	t.add(&Task{name: name, deps: deps, fn: fn})
+	return t
+}
+
+// Parallel sets the maximum number of tasks running at the same time. Values less than 1 mean 1.
+func (t *Tasks) Parallel(n int) *Tasks {
+	t.parallel = max(n, 1)
+	return t
+}
+
+func (t *Tasks) add(task *Task) {
+	if _, ok := t.tasks[task.name]; !ok {
+		t.order = append(t.order, task.name)
+	}
+
+	t.tasks[task.name] = task
+}
+
+// Validate checks that all dependencies are defined and that there are no cycles
+// between the given targets and their dependencies. No targets means all tasks.
+func (t *Tasks) Validate(targets ...string) error {
+	_, err := t.plan(targets)
+	return err
+}
+
+// plan returns the tasks needed to run targets, dependencies first
+func (t *Tasks) plan(targets []string) ([]*Task, error) {
+	if len(targets) == 0 {
+		targets = t.order
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := map[string]int{}
+	var sorted []*Task
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		task, ok := t.tasks[name]
+		if !ok {
+			if len(path) == 0 {
+				return fmt.Errorf("task %q is not defined", name)
+			}
+			return fmt.Errorf("task %q depends on undefined task %q", path[len(path)-1], name)
+		}
+
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for i, p := range path {
+				if p == name {
+					start = i
+				}
+			}
+			cycle := append(path[start:], name)
+			return fmt.Errorf("task dependency cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range task.deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		sorted = append(sorted, task)
+
+		return nil
+	}
+
+	for _, target := range targets {
+		if err := visit(target); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// Run executes targets and their dependencies, no targets means all tasks.
+// Tasks whose dependencies failed or were skipped are skipped, other tasks keep running.
+//
+// The returned error is non-nil if the graph is invalid (undefined task or cycle), in which
+// case no task is executed, or if any task failed, in which case it equals results.Error().
+func (t *Tasks) Run(targets ...string) (TaskResults, error) {
+	plan, err := t.plan(targets)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+	for _, task := range plan {
+		pending[task.name] = len(task.deps)
+		for _, dep := range task.deps {
+			dependents[dep] = append(dependents[dep], task.name)
+		}
+	}
+
+	// skipped holds the reason for skipping a task
+	skipped := map[string]error{}
+	results := make(TaskResults, 0, len(plan))
+	finished := make(chan TaskResult)
+	var ready []*Task
+	running := 0
+
+	for _, task := range plan {
+		if pending[task.name] == 0 {
+			ready = append(ready, task)
+		}
+	}
+
+	for len(results) < len(plan) {
+		for len(ready) > 0 && running < t.parallel {
+			task := ready[0]
+			ready = ready[1:]
+
+			if reason, ok := skipped[task.name]; ok {
+				results = append(results, TaskResult{Name: task.name, Status: TaskSkipped, Err: reason, Command: task.cmd})
+				t.release(task.name, nil, dependents, pending, skipped, &ready)
+				continue
+			}
+
+			running++
+			go func() {
+				result := TaskResult{Name: task.name, Start: time.Now(), Command: task.cmd}
+				result.Err = task.run()
+				result.Duration = time.Since(result.Start)
+				if result.Err != nil {
+					result.Status = TaskFailed
+				}
+				finished <- result
+			}()
+		}
+
+		if running == 0 {
+			continue
+		}
+
+		result := <-finished
+		running--
+		results = append(results, result)
+
+		var reason error
+		if result.Status != TaskSucceeded {
+			reason = fmt.Errorf("dependency %q failed", result.Name)
+		}
+		t.release(result.Name, reason, dependents, pending, skipped, &ready)
+	}
+
+	return results, results.Error()
+}
+
+// release marks name as finished, queueing its dependents that have no pending dependencies left.
+// If reason is not nil the dependents are marked to be skipped.
+func (t *Tasks) release(name string, reason error, dependents map[string][]string, pending map[string]int, skipped map[string]error, ready *[]*Task) {
+	if reason == nil {
+		reason = skipped[name]
+	}
+
+	for _, dependent := range dependents[name] {
+		if _, ok := skipped[dependent]; !ok && reason != nil {
+			skipped[dependent] = reason
+		}
+
+		pending[dependent]--
+		if pending[dependent] == 0 {
+			*ready = append(*ready, t.tasks[dependent])
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package types
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTasks_Run(t *testing.T) {
+	t.Run("runs dependencies before dependents", func(t *testing.T) {
+		var mu sync.Mutex
+		var order []string
+		record := func(name string) func() error {
+			return func() error {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, name)
+				return nil
+			}
+		}
+
+		results, err := NewTasks().
+			Fn("test", record("test"), "build").
+			Fn("build", record("build"), "deps").
+			Fn("deps", record("deps")).
+			Run("test")
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"deps", "build", "test"}, order)
+		require.Len(t, results, 3)
+	})
+
+	t.Run("runs only targets and their dependencies", func(t *testing.T) {
+		var ran atomic.Int32
+		fn := func() error { ran.Add(1); return nil }
+
+		results, err := NewTasks().
+			Fn("a", fn).
+			Fn("b", fn, "a").
+			Fn("unrelated", fn).
+			Run("b")
+
+		require.NoError(t, err)
+		require.Equal(t, int32(2), ran.Load())
+		_, ok := results.Get("unrelated")
+		require.False(t, ok)
+	})
+
+	t.Run("no targets runs all tasks", func(t *testing.T) {
+		results, err := NewTasks().
+			Fn("a", func() error { return nil }).
+			Fn("b", func() error { return nil }).
+			Run()
+
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+	})
+
+	t.Run("runs commands", func(t *testing.T) {
+		cmd := Cmd("echo", "hello")
+		results, err := NewTasks().Cmd("echo", cmd).Run()
+
+		require.NoError(t, err)
+		result, ok := results.Get("echo")
+		require.True(t, ok)
+		require.Equal(t, TaskSucceeded, result.Status)
+		require.Same(t, cmd, result.Command)
+		require.Equal(t, "hello\n", result.Command.Stdout())
+	})
+
+	t.Run("skips dependents of failed tasks", func(t *testing.T) {
+		var ran atomic.Bool
+		results, err := NewTasks().
+			Cmd("build", Cmd("false")).
+			Fn("test", func() error { ran.Store(true); return nil }, "build").
+			Fn("release", func() error { ran.Store(true); return nil }, "test").
+			Fn("lint", func() error { return nil }).
+			Run()
+
+		require.Error(t, err)
+		require.False(t, ran.Load())
+
+		build, _ := results.Get("build")
+		require.Equal(t, TaskFailed, build.Status)
+
+		test, _ := results.Get("test")
+		require.Equal(t, TaskSkipped, test.Status)
+		require.EqualError(t, test.Err, `dependency "build" failed`)
+
+		release, _ := results.Get("release")
+		require.Equal(t, TaskSkipped, release.Status)
+		require.EqualError(t, release.Err, `dependency "build" failed`)
+
+		lint, _ := results.Get("lint")
+		require.Equal(t, TaskSucceeded, lint.Status)
+	})
+
+	t.Run("error contains failed tasks only", func(t *testing.T) {
+		boom := errors.New("boom")
+		_, err := NewTasks().
+			Fn("a", func() error { return boom }).
+			Fn("b", func() error { return nil }, "a").
+			Run()
+
+		require.ErrorIs(t, err, boom)
+		require.EqualError(t, err, `task "a": boom`)
+	})
+
+	t.Run("runs independent tasks in parallel", func(t *testing.T) {
+		var current, peak atomic.Int32
+		fn := func() error {
+			n := current.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			current.Add(-1)
+			return nil
+		}
+
+		_, err := NewTasks().
+			Fn("a", fn).Fn("b", fn).Fn("c", fn).Fn("d", fn).
+			Parallel(2).
+			Run()
+
+		require.NoError(t, err)
+		require.Equal(t, int32(2), peak.Load())
+	})
+
+	t.Run("parallel less than one runs sequentially", func(t *testing.T) {
+		tasks := NewTasks().Parallel(0)
+		require.Equal(t, 1, tasks.parallel)
+	})
+}
+
+func TestTasks_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tasks   *Tasks
+		targets []string
+		wantErr string
+	}{
+		{
+			name:  "valid graph",
+			tasks: NewTasks().Fn("a", nil).Fn("b", nil, "a"),
+		},
+		{
+			name:    "undefined target",
+			tasks:   NewTasks().Fn("a", nil),
+			targets: []string{"missing"},
+			wantErr: `task "missing" is not defined`,
+		},
+		{
+			name:    "undefined dependency",
+			tasks:   NewTasks().Fn("a", nil, "missing"),
+			wantErr: `task "a" depends on undefined task "missing"`,
+		},
+		{
+			name:    "self cycle",
+			tasks:   NewTasks().Fn("a", nil, "a"),
+			wantErr: "task dependency cycle detected: a -> a",
+		},
+		{
+			name:    "indirect cycle",
+			tasks:   NewTasks().Fn("a", nil, "b").Fn("b", nil, "c").Fn("c", nil, "b"),
+			wantErr: "task dependency cycle detected: b -> c -> b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tasks.Validate(tt.targets...)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.EqualError(t, err, tt.wantErr)
+
+			results, err := tt.tasks.Run(tt.targets...)
+			require.EqualError(t, err, tt.wantErr)
+			require.Nil(t, results)
+		})
+	}
+}
+
+func TestTaskResults_Summary(t *testing.T) {
+	results := TaskResults{
+		{Name: "build", Status: TaskSucceeded, Duration: 1500 * time.Millisecond},
+		{Name: "test", Status: TaskFailed, Err: errors.New("exit status 1"), Duration: 20 * time.Millisecond},
+		{Name: "release", Status: TaskSkipped, Err: errors.New(`dependency "test" failed`)},
+	}
+
+	expected := "" +
+		"ok      build         1.5s\n" +
+		"failed  test          20ms  exit status 1\n" +
+		"skipped release         0s  dependency \"test\" failed\n" +
+		"3 tasks, 1.52s total task time\n"
+
+	require.Equal(t, expected, results.Summary())
+}
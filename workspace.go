@@ -0,0 +1,242 @@
+package types
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Workspace is a temporary directory seeded with files to run commands in.
+// Close removes it with everything commands created inside it.
+//
+// Example:
+//
+//	ws, err := types.NewWorkspace()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer ws.Close()
+//
+//	err = ws.WriteFiles(map[string]string{"go.mod": "module example\n", "main.go": source})
+//	output := ws.Cmd("go", "build", "-o", "bin/app", ".").StdoutStderr()
+//	files, err := ws.Snapshot() // files["bin/app"].Mode is executable
+type Workspace struct {
+	dir string
+}
+
+// WorkspaceFile is an entry of a Workspace snapshot
+type WorkspaceFile struct {
+	// Path is relative to the workspace and slash separated
+	Path string
+	// Content is the file content, or the target of a symbolic link, empty for directories
+	Content string
+	Mode    fs.FileMode
+}
+
+// NewWorkspace creates an empty workspace in a new temporary directory
+func NewWorkspace() (*Workspace, error) {
+	dir, err := os.MkdirTemp("", "workspace-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Workspace{dir: dir}, nil
+}
+
+// CleanupWith removes the workspace when t finishes, t is usually a *testing.T
+//
+// Example:
+//
+//	ws, err := types.NewWorkspace()
+//	require.NoError(t, err)
+//	ws.CleanupWith(t)
+func (w *Workspace) CleanupWith(t interface{ Cleanup(func()) }) *Workspace {
+	t.Cleanup(func() { w.Close() })
+	return w
+}
+
+// Dir returns the path of the workspace directory
+func (w *Workspace) Dir() string { return w.dir }
+
+// Close removes the workspace directory and its content
+func (w *Workspace) Close() error { return os.RemoveAll(w.dir) }
+
+// path returns the absolute path of a slash separated name relative to the workspace,
+// names escaping the workspace are rejected
+func (w *Workspace) path(name string) (string, error) {
+	local := filepath.FromSlash(name)
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("workspace: %q is outside the workspace", name)
+	}
+
+	return filepath.Join(w.dir, local), nil
+}
+
+// WriteFiles writes files to the workspace, keys are slash separated paths relative to
+// the workspace and values are contents. Parent directories are created as needed and
+// existing files are overwritten.
+//
+// Example:
+//
+//	err := ws.WriteFiles(map[string]string{
+//		"config/app.yaml": "port: 8080\n",
+//		"data/input.csv":  "id,name\n1,go\n",
+//	})
+func (w *Workspace) WriteFiles(files map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if err := w.writeFile(name, []byte(files[name]), 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CopyFS copies all files of fsys to the workspace, like an embed.FS of fixtures.
+// Executable files stay executable, other files are written with mode 0644.
+//
+// Example:
+//
+//	//go:embed testdata/project
+//	var project embed.FS
+//
+//	fixtures, _ := fs.Sub(project, "testdata/project")
+//	err := ws.CopyFS(fixtures)
+func (w *Workspace) CopyFS(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+
+		if entry.IsDir() {
+			dir, err := w.path(name)
+			if err != nil {
+				return err
+			}
+			return os.MkdirAll(dir, 0o755)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		mode := fs.FileMode(0o644)
+		if info.Mode().Perm()&0o111 != 0 {
+			mode = 0o755
+		}
+
+		return w.writeFile(name, content, mode)
+	})
+}
+
+func (w *Workspace) writeFile(name string, content []byte, mode fs.FileMode) error {
+	file, err := w.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(file, content, mode); err != nil {
+		return err
+	}
+
+	// WriteFile keeps the mode of existing files
+	return os.Chmod(file, mode)
+}
+
+// Cmd creates a Command running in the workspace
+//
+// Example:
+//
+//	output := ws.Cmd("ls", "-R").Stdout()
+func (w *Workspace) Cmd(cmd string, args ...string) *Command {
+	return Cmd(cmd, args...).Dir(w.dir)
+}
+
+// Run sets the workspace as the working directory of every command of cmd that has
+// none, including piped and sequenced commands, then executes it.
+//
+// Example:
+//
+//	cmd := ws.Run(types.Cmd("git", "init").And(types.Cmd("git", "add", ".")))
+//	err := cmd.Error()
+func (w *Workspace) Run(cmd *Command) *Command {
+	seen := map[*Command]bool{}
+
+	var visit func(c *Command)
+	visit = func(c *Command) {
+		if c == nil || seen[c] {
+			return
+		}
+		seen[c] = true
+
+		for _, child := range slices.Concat([]*Command{c.previous, c.seqLeft, c.seqRight}, c.teeBranches) {
+			visit(child)
+		}
+
+		if c.dir == "" {
+			c.dir = w.dir
+		}
+	}
+	visit(cmd)
+
+	return cmd.Run()
+}
+
+// Snapshot returns every file, directory and symbolic link in the workspace by its
+// slash separated path, for assertions on what commands produced.
+//
+// Example:
+//
+//	files, err := ws.Snapshot()
+//	fmt.Println(files["out/report.txt"].Content)
+func (w *Workspace) Snapshot() (map[string]WorkspaceFile, error) {
+	files := map[string]WorkspaceFile{}
+
+	err := filepath.WalkDir(w.dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || file == w.dir {
+			return err
+		}
+
+		rel, err := filepath.Rel(w.dir, file)
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		snapshot := WorkspaceFile{Path: filepath.ToSlash(rel), Mode: info.Mode()}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			snapshot.Content, err = os.Readlink(file)
+		case info.Mode().IsRegular():
+			var content []byte
+			content, err = os.ReadFile(file)
+			snapshot.Content = string(content)
+		}
+		if err != nil {
+			return err
+		}
+
+		files[snapshot.Path] = snapshot
+
+		return nil
+	})
+
+	return files, err
+}
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspace(t *testing.T) {
+	t.Run("writes fixtures and snapshots the result", func(t *testing.T) {
+		ws, err := NewWorkspace()
+		require.NoError(t, err)
+		ws.CleanupWith(t)
+
+		require.NoError(t, ws.WriteFiles(map[string]string{
+			"input.txt":       "b\na\n",
+			"config/app.conf": "port=80\n",
+		}))
+		require.NoError(t, ws.CopyFS(fstest.MapFS{
+			"bin/run.sh":     {Data: []byte("#!/bin/sh\necho run\n"), Mode: 0o755},
+			"data/empty.txt": {Data: nil, Mode: 0o444},
+		}))
+
+		require.Equal(t, "run\n", ws.Cmd("./bin/run.sh").Stdout())
+		require.NoError(t, ws.Cmd("sh", "-c", "sort input.txt > sorted.txt && ln -s sorted.txt link").Error())
+
+		// directories and files created by commands depend on the umask
+		mode := func(name string) fs.FileMode {
+			info, err := os.Lstat(filepath.Join(ws.Dir(), name))
+			require.NoError(t, err)
+			return info.Mode()
+		}
+
+		files, err := ws.Snapshot()
+		require.NoError(t, err)
+		require.Equal(t, map[string]WorkspaceFile{
+			"bin":             {Path: "bin", Mode: mode("bin")},
+			"bin/run.sh":      {Path: "bin/run.sh", Content: "#!/bin/sh\necho run\n", Mode: 0o755},
+			"config":          {Path: "config", Mode: mode("config")},
+			"config/app.conf": {Path: "config/app.conf", Content: "port=80\n", Mode: 0o644},
+			"data":            {Path: "data", Mode: mode("data")},
+			"data/empty.txt":  {Path: "data/empty.txt", Mode: 0o644},
+			"input.txt":       {Path: "input.txt", Content: "b\na\n", Mode: 0o644},
+			"link":            {Path: "link", Content: "sorted.txt", Mode: mode("link")},
+			"sorted.txt":      {Path: "sorted.txt", Content: "a\nb\n", Mode: mode("sorted.txt")},
+		}, files)
+		require.True(t, mode("bin").IsDir())
+		require.Equal(t, fs.ModeSymlink, mode("link").Type())
+	})
+
+	t.Run("runs commands in the workspace", func(t *testing.T) {
+		ws, err := NewWorkspace()
+		require.NoError(t, err)
+		ws.CleanupWith(t)
+		require.NoError(t, ws.WriteFiles(map[string]string{"a.txt": "hello\n"}))
+
+		cmd := ws.Run(Cmd("cat", "a.txt").Pipe("tr", "a-z", "A-Z").And(Cmd("ls")))
+		require.NoError(t, cmd.Error())
+		require.Equal(t, "HELLO\na.txt\n", cmd.Stdout())
+
+		other := t.TempDir()
+		require.Equal(t, other+"\n", ws.Run(Cmd("pwd").Dir(other)).Stdout())
+	})
+
+	t.Run("rejects paths outside the workspace", func(t *testing.T) {
+		ws, err := NewWorkspace()
+		require.NoError(t, err)
+		ws.CleanupWith(t)
+
+		require.EqualError(t, ws.WriteFiles(map[string]string{"../escape": ""}), `workspace: "../escape" is outside the workspace`)
+		require.Error(t, ws.WriteFiles(map[string]string{"/etc/escape": ""}))
+	})
+
+	t.Run("close removes the workspace", func(t *testing.T) {
+		ws, err := NewWorkspace()
+		require.NoError(t, err)
+		require.NoError(t, ws.WriteFiles(map[string]string{"dir/file": "content"}))
+
+		require.NoError(t, ws.Close())
+		_, err = os.Stat(ws.Dir())
+		require.ErrorIs(t, err, os.ErrNotExist)
+		require.True(t, filepath.IsAbs(ws.Dir()))
+	})
+}